@@ -2,13 +2,13 @@ package cmd
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"wget/flag"
 	"wget/logger"
@@ -17,6 +17,7 @@ import (
 	"wget/utils"
 
 	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/vbauerster/mpb"
@@ -35,7 +36,68 @@ func init() {
 	rootCmd.Flags().BoolVar(flag.Mirror, flag.GetFlagName(flag.MIRROR_FLAG), false, "Enables site mirroring to download and locally replicate a complete website, adjusting all internal links for offline navigation. Useful for offline content access and backup.")
 	rootCmd.Flags().StringSliceVarP(flag.Reject, flag.GetFlagName(flag.REJECT_FLAG), "R", []string{}, "Define a list of file suffixes to avoid")
 	rootCmd.Flags().StringSliceVarP(flag.Excludes, flag.GetFlagName(flag.EXCLUDE_FLAG), "X", []string{}, "Define a list of directory to ignore")
+	rootCmd.Flags().StringSliceVarP(flag.Accept, flag.GetFlagName(flag.ACCEPT_FLAG), "A", []string{}, "Only save files with these suffixes, name patterns or MIME types (e.g., pdf,*.jpg,image/*)")
+	rootCmd.Flags().StringVar(flag.AcceptRegex, flag.GetFlagName(flag.ACCEPT_REGEX_FLAG), "", "Only save files whose full URL matches this regex")
+	rootCmd.Flags().StringVar(flag.RejectRegex, flag.GetFlagName(flag.REJECT_REGEX_FLAG), "", "Don't save files whose full URL matches this regex")
+	rootCmd.Flags().StringSliceVarP(flag.Includes, flag.GetFlagName(flag.INCLUDE_FLAG), "I", []string{}, "Only crawl these directories")
+	rootCmd.Flags().BoolVar(flag.NoFollowRej, flag.GetFlagName(flag.NO_FOLLOW_REJECTED_FLAG), false, "Don't read rejected HTML pages for links")
 	rootCmd.Flags().BoolVar(flag.Convert, flag.GetFlagName(flag.CONVERT_FLAG), false, "convert the links so that they can be viewed offline")
+	rootCmd.Flags().BoolVarP(flag.Continue, flag.GetFlagName(flag.CONTINUE_FLAG), "c", false, "Resume getting a partially-downloaded file")
+	rootCmd.Flags().IntVar(flag.Segments, flag.GetFlagName(flag.SEGMENTS_FLAG), 0, "Split a large file into N byte ranges downloaded in parallel")
+	rootCmd.Flags().IntVarP(flag.Tries, flag.GetFlagName(flag.TRIES_FLAG), "t", 20, "Set number of tries on transient errors (0 for unlimited)")
+	rootCmd.Flags().IntVar(flag.WaitRetry, flag.GetFlagName(flag.WAITRETRY_FLAG), 10, "Wait at most N seconds between retries, backing off exponentially")
+	rootCmd.Flags().Float64VarP(flag.Timeout, flag.GetFlagName(flag.TIMEOUT_FLAG), "T", 0, "Set the connect and read timeouts to N seconds")
+	rootCmd.Flags().Float64Var(flag.ConnTimeout, flag.GetFlagName(flag.CONNECT_TIMEOUT_FLAG), 0, "Give up on DNS, connect and TLS handshake after N seconds")
+	rootCmd.Flags().Float64Var(flag.ReadTimeout, flag.GetFlagName(flag.READ_TIMEOUT_FLAG), 0, "Give up when no data arrives for N seconds (default 900)")
+	rootCmd.Flags().IntVar(flag.MaxConns, flag.GetFlagName(flag.MAX_CONNS_FLAG), 16, "Maximum number of connections kept open to a single host (0 for unlimited)")
+	rootCmd.Flags().StringArrayVar(flag.Headers, flag.GetFlagName(flag.HEADER_FLAG), []string{}, "Add a \"Name: value\" header to every request (repeatable)")
+	rootCmd.Flags().StringArrayVar(flag.HeaderEnvs, flag.GetFlagName(flag.HEADER_ENV_FLAG), []string{}, "Add a header whose value is read from an environment variable, as Name=VARIABLE (repeatable)")
+	rootCmd.Flags().StringVarP(flag.UserAgent, flag.GetFlagName(flag.USER_AGENT_FLAG), "U", "", "Identify as the given User-Agent")
+	rootCmd.Flags().StringVar(flag.Referer, flag.GetFlagName(flag.REFERER_FLAG), "", "Include a Referer header in every request")
+	rootCmd.Flags().StringVar(flag.User, flag.GetFlagName(flag.USER_FLAG), "", "Username for HTTP authentication")
+	rootCmd.Flags().StringVar(flag.Password, flag.GetFlagName(flag.PASSWORD_FLAG), "", "Password for HTTP authentication")
+	rootCmd.Flags().BoolVar(flag.AskPassword, flag.GetFlagName(flag.ASK_PASSWORD_FLAG), false, "Prompt for the HTTP authentication password")
+	rootCmd.Flags().StringVar(flag.LoadCookies, flag.GetFlagName(flag.LOAD_COOKIES_FLAG), "", "Load cookies from a Netscape cookies.txt file before the first request")
+	rootCmd.Flags().StringVar(flag.SaveCookies, flag.GetFlagName(flag.SAVE_COOKIES_FLAG), "", "Save cookies to a Netscape cookies.txt file when done")
+	rootCmd.Flags().BoolVar(flag.KeepSession, flag.GetFlagName(flag.KEEP_SESSION_COOKIES_FLAG), false, "Also save session cookies with --save-cookies")
+	rootCmd.Flags().StringVar(flag.Proxy, flag.GetFlagName(flag.PROXY_FLAG), "", "Send requests through an HTTP, HTTPS or SOCKS5 proxy (e.g., http://host:3128 or socks5://host:1080)")
+	rootCmd.Flags().BoolVar(flag.NoProxy, flag.GetFlagName(flag.NO_PROXY_FLAG), false, "Don't use proxies, even if the proxy environment variables are set")
+	rootCmd.Flags().StringVar(flag.ProxyUser, flag.GetFlagName(flag.PROXY_USER_FLAG), "", "Username for proxy authentication")
+	rootCmd.Flags().StringVar(flag.ProxyPass, flag.GetFlagName(flag.PROXY_PASSWORD_FLAG), "", "Password for proxy authentication")
+	rootCmd.Flags().StringVar(flag.CACert, flag.GetFlagName(flag.CA_CERTIFICATE_FLAG), "", "Trust the CA certificates of a PEM bundle in addition to the system ones")
+	rootCmd.Flags().StringVar(flag.CADir, flag.GetFlagName(flag.CA_DIRECTORY_FLAG), "", "Trust the PEM CA certificates found in a directory")
+	rootCmd.Flags().StringVar(flag.Cert, flag.GetFlagName(flag.CERTIFICATE_FLAG), "", "Client certificate (PEM) for mutual TLS")
+	rootCmd.Flags().StringVar(flag.PrivateKey, flag.GetFlagName(flag.PRIVATE_KEY_FLAG), "", "Private key (PEM) of the client certificate, if not in the certificate file")
+	rootCmd.Flags().StringVar(flag.PinnedKey, flag.GetFlagName(flag.PINNED_PUBKEY_FLAG), "", "Only accept servers whose public key matches sha256//<base64 hash> (separate several with ;)")
+	rootCmd.Flags().StringVar(flag.MinTLS, flag.GetFlagName(flag.MIN_TLS_FLAG), "1.2", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	rootCmd.Flags().BoolVar(flag.NoCheckCert, flag.GetFlagName(flag.NO_CHECK_CERTIFICATE_FLAG), false, "Don't verify the server certificate (insecure)")
+	rootCmd.Flags().BoolVar(flag.Fsync, flag.GetFlagName(flag.FSYNC_FLAG), false, "Flush each file to disk before moving it into place")
+	rootCmd.Flags().BoolVar(flag.NoClobber, flag.GetFlagName(flag.NO_CLOBBER_FLAG), false, "Skip downloads that would overwrite an existing file")
+	rootCmd.Flags().BoolVar(flag.Overwrite, flag.GetFlagName(flag.OVERWRITE_FLAG), false, "Replace existing files instead of saving as file.1, file.2, ...")
+	rootCmd.Flags().IntVar(flag.Backups, flag.GetFlagName(flag.BACKUPS_FLAG), 0, "Replace existing files, keeping up to N previous versions as file.1 ... file.N")
+	rootCmd.Flags().BoolVarP(flag.Timestamp, flag.GetFlagName(flag.TIMESTAMPING_FLAG), "N", false, "Only download files that are newer than the local copy")
+	rootCmd.Flags().StringVar(flag.Checksum, flag.GetFlagName(flag.CHECKSUM_FLAG), "", "Verify the downloaded file against algo:hex (md5, sha1, sha256 or sha512)")
+	rootCmd.Flags().StringVar(flag.PrintDigest, flag.GetFlagName(flag.PRINT_DIGEST_FLAG), "", "Print the digest of every downloaded file (default algorithm sha256)")
+	rootCmd.Flags().Lookup(flag.GetFlagName(flag.PRINT_DIGEST_FLAG)).NoOptDefVal = "sha256"
+	rootCmd.Flags().IntVar(flag.MaxRedirect, flag.GetFlagName(flag.MAX_REDIRECT_FLAG), 20, "Follow at most N redirects per request")
+	rootCmd.Flags().BoolVar(flag.NoCrossHost, flag.GetFlagName(flag.NO_CROSS_HOST_REDIRECTS_FLAG), false, "Refuse redirects that leave the host of the original URL")
+	rootCmd.Flags().BoolVar(flag.NoDowngrade, flag.GetFlagName(flag.NO_HTTPS_DOWNGRADE_FLAG), false, "Refuse redirects from HTTPS to HTTP")
+	rootCmd.Flags().BoolVar(flag.TrustNames, flag.GetFlagName(flag.TRUST_SERVER_NAMES_FLAG), false, "Name files after the last URL of a redirect chain instead of the original one")
+	rootCmd.Flags().BoolVar(flag.Spider, flag.GetFlagName(flag.SPIDER_FLAG), false, "Check that pages exist without saving them; with --mirror, crawl the site and report broken links")
+	rootCmd.Flags().IntVarP(flag.Level, flag.GetFlagName(flag.LEVEL_FLAG), "l", 0, "Follow links at most N levels deep from the first page when mirroring (0 for unlimited)")
+	rootCmd.Flags().IntVar(flag.MaxPages, flag.GetFlagName(flag.MAX_PAGES_FLAG), 0, "Stop mirroring after fetching N files")
+	rootCmd.Flags().StringVar(flag.Quota, flag.GetFlagName(flag.QUOTA_FLAG), "", "Stop mirroring once this many bytes are saved (e.g., 500M or 2G)")
+	rootCmd.Flags().DurationVar(flag.MaxDuration, flag.GetFlagName(flag.MAX_DURATION_FLAG), 0, "Stop mirroring after this long (e.g., 30m or 2h)")
+	rootCmd.Flags().StringVar(flag.Sitemap, flag.GetFlagName(flag.SITEMAP_FLAG), "", "Also mirror the pages listed in sitemaps, found in robots.txt or at the given URL")
+	rootCmd.Flags().Lookup(flag.GetFlagName(flag.SITEMAP_FLAG)).NoOptDefVal = "auto"
+	rootCmd.Flags().BoolVarP(flag.PageReqs, flag.GetFlagName(flag.PAGE_REQUISITES_FLAG), "p", false, "Download the images, stylesheets, scripts and media a page needs to display, from any host")
+	rootCmd.Flags().BoolVar(flag.NoParent, flag.GetFlagName(flag.NO_PARENT_FLAG), false, "Never crawl above the directory of the first page (also -np)")
+	rootCmd.Flags().StringVar(flag.Scope, flag.GetFlagName(flag.SCOPE_FLAG), "", "Crawl the whole host, the directory of the first page, or the URLs its path is a prefix of: host, directory or prefix (default host)")
+	rootCmd.Flags().BoolVarP(flag.SpanHosts, flag.GetFlagName(flag.SPAN_HOSTS_FLAG), "H", false, "Follow links to other hosts when mirroring")
+	rootCmd.Flags().StringSliceVarP(flag.Domains, flag.GetFlagName(flag.DOMAINS_FLAG), "D", []string{}, "Also mirror these domains and their subdomains")
+	rootCmd.Flags().StringSliceVar(flag.ExclDomains, flag.GetFlagName(flag.EXCLUDE_DOMAINS_FLAG), []string{}, "Never mirror these domains and their subdomains")
+	rootCmd.Flags().StringArrayVarP(flag.Execute, flag.GetFlagName(flag.EXECUTE_FLAG), "e", []string{}, "Run a .wgetrc-style command, such as robots=off to ignore robots.txt (repeatable)")
+	rootCmd.Flags().StringVar(flag.Compression, flag.GetFlagName(flag.COMPRESSION_FLAG), "auto", "Ask for compressed responses and decode them: auto, gzip or none (keep the body as sent)")
 
 	state.InitNewState()
 }
@@ -53,7 +115,7 @@ var rootCmd = &cobra.Command{
 		if len(args) == 0 && *flag.GetFlagValue(flag.INPUT_FLAG).(*string) == "" {
 			return fmt.Errorf("invalid argument")
 		}
-		return nil
+		return net.InitClient()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fn := Exec(cmd, args)
@@ -62,10 +124,22 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
+	// pflag shorthands are single letters, so wget's -np is spelled out.
+	args := os.Args[1:]
+	for i, arg := range args {
+		if arg == "-np" {
+			args[i] = "--" + flag.GetFlagName(flag.NO_PARENT_FLAG)
+		}
+	}
+	rootCmd.SetArgs(args)
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	if state.Failed() {
+		os.Exit(1)
+	}
 	os.Exit(0)
 }
 
@@ -84,9 +158,17 @@ func Exec(cmd *cobra.Command, args []string) func() {
 		return func() {
 			startMsg := fmt.Sprintf("#Started at: %s", utils.GetCurrentTime())
 			fmt.Println(startMsg)
+			loadCookies()
 			// wg.Add(1)
 			MirrorExec(p, &wg, flag.GetUrls()[0])
 			p.Wait()
+			convertFiles()
+			spiderReport()
+			if reason := state.StopReason(); reason != "" {
+				fmt.Printf("Stopped mirroring early: %s.\n", reason)
+			}
+			saveCookies()
+			saveETags()
 
 			endMsg := fmt.Sprintf("#Finished at: %s\n", utils.GetCurrentTime())
 			fmt.Println(endMsg)
@@ -97,6 +179,7 @@ func Exec(cmd *cobra.Command, args []string) func() {
 		startMsg := fmt.Sprintf("#Started at: %s", utils.GetCurrentTime())
 		fmt.Println(startMsg)
 		fmt.Printf("#Files: %v\n", len(flag.GetUrls()))
+		loadCookies()
 		for _, url := range flag.GetUrls() {
 			wg.Add(1)
 			go func(url string) {
@@ -105,6 +188,9 @@ func Exec(cmd *cobra.Command, args []string) func() {
 			}(url)
 		}
 		p.Wait()
+		spiderReport()
+		saveCookies()
+		saveETags()
 
 		endMsg := fmt.Sprintf("#Finished at: %s", utils.GetCurrentTime())
 		fmt.Println(endMsg)
@@ -112,15 +198,55 @@ func Exec(cmd *cobra.Command, args []string) func() {
 }
 
 func defaultExec(p *mpb.Progress, url string) {
+	if flag.Provided(flag.SPIDER_FLAG) {
+		net.Spider(url)
+		return
+	}
 	net.GetWithSpeedLimit(p, url, flag.GetRateLimit())
 }
 
+func spiderReport() {
+	if flag.Provided(flag.SPIDER_FLAG) {
+		net.SpiderReport()
+	}
+}
+
+func loadCookies() {
+	if !flag.Provided(flag.LOAD_COOKIES_FLAG) {
+		return
+	}
+	err := net.LoadCookies(*flag.GetFlagValue(flag.LOAD_COOKIES_FLAG).(*string))
+	if err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func saveCookies() {
+	if !flag.Provided(flag.SAVE_COOKIES_FLAG) {
+		return
+	}
+	err := net.SaveCookies(*flag.GetFlagValue(flag.SAVE_COOKIES_FLAG).(*string), flag.Provided(flag.KEEP_SESSION_COOKIES_FLAG))
+	if err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+	}
+}
+
+func saveETags() {
+	if err := net.SaveETags(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+	}
+}
+
 func runInBackground() {
 	cmd := exec.Command(os.Args[0], flag.GetArgs()...)
 	cmd.Stdout = logger.OUT
 	cmd.Stderr = nil
 	cmd.Stdin = nil
 	env := append(os.Environ(), "WGET_BACKGROUND=1")
+	if flag.Provided(flag.ASK_PASSWORD_FLAG) {
+		env = append(env, flag.PASSWORD_ENV+"="+*flag.Password)
+	}
 
 	cmd.Env = env
 	err := cmd.Start()
@@ -143,12 +269,22 @@ func MirrorExec(p *mpb.Progress, wg *sync.WaitGroup, u string) {
 	state.SetBaseUrl(parsedUrl)
 	host := parsedUrl.Host
 	path := filepath.Join(*flag.GetFlagValue(flag.PATH_FLAG).(*string), host)
-	err = os.MkdirAll(path, 0755)
+	if !flag.Provided(flag.SPIDER_FLAG) {
+		err = os.MkdirAll(path, 0755)
+	}
 	if err != nil {
 		os.Stderr.WriteString("cannot create the directory " + err.Error() + "\n")
 		os.Exit(1)
 	}
-	flag.SetOutputPath(path)
+
+	net.LoadRobots(parsedUrl)
+	state.SetDepth(u, 0)
+	state.SetBudget(*flag.GetFlagValue(flag.MAX_PAGES_FLAG).(*int), flag.GetQuota())
+	if maxDuration := *flag.GetFlagValue(flag.MAX_DURATION_FLAG).(*time.Duration); maxDuration > 0 {
+		time.AfterFunc(maxDuration, func() {
+			state.Stop(fmt.Sprintf("reached --max-duration of %v", maxDuration))
+		})
+	}
 
 	go ExtractURLs(wg)
 	go processLinks(p, wg)
@@ -158,21 +294,55 @@ func MirrorExec(p *mpb.Progress, wg *sync.WaitGroup, u string) {
 	go func() {
 		mirror(p, u)
 	}()
+
+	if flag.Provided(flag.SITEMAP_FLAG) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seedSitemaps(wg, parsedUrl)
+		}()
+	}
+}
+
+// seedSitemaps crawls the pages listed in sitemaps, which nothing may link
+// to, as if they were found on the first page.
+func seedSitemaps(wg *sync.WaitGroup, base *url.URL) {
+	for _, l := range net.Sitemaps(base, *flag.GetFlagValue(flag.SITEMAP_FLAG).(*string)) {
+		if _, loaded := state.GetVisitedLinks().Load(l); !loaded {
+			state.SetDepth(l, 0)
+			wg.Add(1)
+			state.AddLink(l)
+		}
+	}
 }
 
 func mirror(p *mpb.Progress, u string) {
 
-	if dirIgnored(u) {
+	// The first page is always crawled: -I, -X and the URL regexes apply to
+	// the links found from it.
+	if (u != state.GetBaseUrl().String() && (dirIgnored(u) || net.URLRejected(u))) || !state.Visit(u) {
+		state.Abort(u)
+		return
+	}
+	if !net.RobotsAllowed(u) {
+		fmt.Printf("%s is disallowed by robots.txt\n", u)
 		state.Abort(u)
 		return
 	}
-
-	state.SetVisitedLink(u)
 	limiter := state.GetLimiter()
-	// Wait for rate limiter before making a request
-	err := limiter.Wait(context.Background())
+	// Wait for rate limiter before making a request. Stopping the crawl
+	// wakes every page still waiting, and the page is only counted once it
+	// may start.
+	err := limiter.Wait(state.Stopped())
 	if err != nil {
-		fmt.Printf("Rate limiter error: %v\n", err)
+		if state.StopReason() == "" {
+			fmt.Printf("Rate limiter error: %v\n", err)
+		}
+		state.Abort(u)
+		return
+	}
+	if !state.StartPage() {
+		state.Abort(u)
 		return
 	}
 
@@ -182,14 +352,12 @@ func mirror(p *mpb.Progress, u string) {
 		return
 	}
 
-	relativePath := parsedUrl.Path
-	if relativePath == "" || strings.HasSuffix(relativePath, "/") {
-		relativePath = filepath.Join(relativePath, "index.html")
+	if flag.Provided(flag.SPIDER_FLAG) {
+		defaultExec(p, u)
+		return
 	}
 
-	path := *flag.GetFlagValue(flag.PATH_FLAG).(*string)
-
-	fullPath := filepath.Join(path, relativePath)
+	fullPath := net.MirrorPath(parsedUrl)
 
 	err = os.MkdirAll(filepath.Dir(fullPath), 0755)
 	if err != nil {
@@ -200,93 +368,164 @@ func mirror(p *mpb.Progress, u string) {
 	defaultExec(p, u)
 }
 
+// convertLinks collects the downloaded pages. Their links are converted by
+// convertFiles once the whole site is mirrored, when it is known which URLs
+// redirect to which file.
 func convertLinks(wg *sync.WaitGroup) {
 	for fileToProcess := range state.GetStates().Mirror.FileToProcess {
-		if !flag.Provided(flag.CONVERT_FLAG) {
-			wg.Done()
-			continue
+		// Files kept as the server encoded them are left untouched.
+		if flag.Provided(flag.CONVERT_FLAG) && fileToProcess.Encoding == "" {
+			filesToConvert = append(filesToConvert, fileToProcess)
 		}
-		f, err := os.Open(fileToProcess.Path)
+		wg.Done()
+	}
+}
+
+var filesToConvert []state.FileToProcess
+
+func convertFiles() {
+	for _, fileToProcess := range filesToConvert {
+		convertFile(fileToProcess)
+	}
+}
+
+func convertFile(fileToProcess state.FileToProcess) {
+	f, err := os.Open(fileToProcess.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	fileExt := filepath.Ext(fileToProcess.Path)
+	baseUrl := fileToProcess.Url
+	dir := filepath.Dir(fileToProcess.Path)
+
+	convert := func(link string) string {
+		resolvedUrl, err := baseUrl.Parse(link)
+		// In-page anchors and links like mailto: are kept as they are.
+		if err != nil || strings.HasPrefix(link, "#") || (resolvedUrl.Scheme != "http" && resolvedUrl.Scheme != "https") {
+			return link
+		}
+		// Links to a saved file point to it relative to the page, which
+		// may be under another host directory. Links to anything that
+		// wasn't saved are made absolute.
+		target, ok := state.GetUrlPath(redirected(resolvedUrl))
+		if !ok {
+			return resolvedUrl.String()
+		}
+		fname, err := filepath.Rel(dir, target)
 		if err != nil {
-			wg.Done()
-			continue
+			return resolvedUrl.String()
+		}
+		fname = filepath.ToSlash(fname)
+		if resolvedUrl.Fragment != "" {
+			fname += "#" + resolvedUrl.Fragment
 		}
+		return fname
+	}
 
-		fileExt := filepath.Ext(fileToProcess.Path)
-		baseUrl := fileToProcess.Url
-		doc, err := html.Parse(f)
-		if err != nil || fileExt != ".html" {
-			wg.Done()
-			continue
+	if fileExt == ".css" {
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return
 		}
+		os.WriteFile(fileToProcess.Path, []byte(utils.ReplaceCSSURLs(string(content), convert)), 0644)
+		return
+	}
 
-		var traverse func(*html.Node)
-		traverse = func(n *html.Node) {
-			if n.Type == html.ElementNode {
-				for i, attr := range n.Attr {
-					if isLinkAttribute(attr.Key) {
-						resolvedUrl, err := baseUrl.Parse(attr.Val)
-						if err == nil && utils.IsSameDomain(baseUrl, resolvedUrl.String()) {
-							relativePath := resolvedUrl.Path
-							if relativePath == "" || strings.HasSuffix(relativePath, "/") {
-								relativePath = filepath.Join(relativePath, "index.html")
-							}
-							fname := strings.TrimLeft(relativePath, "/")
-							if filepath.Ext(fname) == "" {
-								fname += ".html"
-							}
-							n.Attr[i].Val = fname
-						}
-					}
+	doc, err := html.Parse(f)
+	if err != nil || fileExt != ".html" {
+		return
+	}
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for i, attr := range n.Attr {
+				if isLinkAttribute(attr.Key) {
+					n.Attr[i].Val = convert(attr.Val)
+				} else if strings.EqualFold(attr.Key, "srcset") {
+					n.Attr[i].Val = convertSrcset(attr.Val, convert)
+				} else if strings.EqualFold(attr.Key, "style") {
+					n.Attr[i].Val = utils.ReplaceCSSURLs(attr.Val, convert)
 				}
 			}
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				traverse(c)
-			}
 		}
-		outputPath := fileToProcess.Path
-		traverse(doc)
-
-		err = os.MkdirAll(filepath.Dir(outputPath), 0755)
-		if err != nil {
-			wg.Done()
-			continue
+		if n.Type == html.TextNode && n.Parent != nil && n.Parent.Data == "style" {
+			n.Data = utils.ReplaceCSSURLs(n.Data, convert)
 		}
-
-		file, err := os.OpenFile(outputPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-		if err != nil {
-			wg.Done()
-			continue
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
 		}
+	}
+	outputPath := fileToProcess.Path
+	traverse(doc)
 
-		err = html.Render(file, doc)
-		if err != nil {
-			wg.Done()
+	err = os.MkdirAll(filepath.Dir(outputPath), 0755)
+	if err != nil {
+		return
+	}
 
-			continue
-		}
-		file.Close()
+	file, err := os.OpenFile(outputPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return
+	}
 
-		utils.ReplaceURLsInFile(fileToProcess.Path)
+	err = html.Render(file, doc)
+	if err != nil {
+		return
+	}
+	file.Close()
+}
 
-		wg.Done()
+// redirected returns the URL a mirrored page was saved as, if u redirected.
+func redirected(u *url.URL) *url.URL {
+	to, ok := state.GetRedirect(u.String())
+	if !ok {
+		return u
+	}
+	if toUrl, err := url.Parse(to); err == nil {
+		return toUrl
 	}
+	return u
 }
 
-func getLinks(r io.Reader) []string {
+type pageLink struct {
+	url string
+	// requisite is set for the resources a page embeds to display, like
+	// images, scripts and stylesheets, as opposed to navigation links.
+	requisite bool
+}
+
+// requisiteTags are the elements that embed the resource they link to.
+var requisiteTags = []string{"img", "script", "video", "audio", "source", "track", "embed", "object", "iframe", "frame", "input"}
+
+// requisiteRels are the <link rel> values of the resources a page needs.
+var requisiteRels = []string{"stylesheet", "icon", "apple-touch-icon", "mask-icon", "preload", "modulepreload", "manifest"}
+
+func getLinks(r io.Reader) []pageLink {
 	content, _ := io.ReadAll(r)
 	doc, err := html.Parse(bytes.NewReader(content))
 	if err != nil {
 		return nil
 	}
 
-	var links []string
+	var links []pageLink
+	nofollow := false
 	var traverse func(*html.Node)
 	traverse = func(n *html.Node) {
-		if n.Type == html.ElementNode {
+		if n.Type == html.ElementNode && flag.Robots() && isNofollowMeta(n) {
+			nofollow = true
+		}
+		if n.Type == html.ElementNode && !(flag.Robots() && hasNofollowRel(n)) {
+			requisite := isRequisite(n)
 			for _, attr := range n.Attr {
 				if isLinkAttribute(attr.Key) {
-					links = append(links, attr.Val)
+					links = append(links, pageLink{attr.Val, requisite})
+				} else if strings.EqualFold(attr.Key, "srcset") {
+					for _, u := range srcsetURLs(attr.Val) {
+						links = append(links, pageLink{u, true})
+					}
 				}
 			}
 		}
@@ -296,11 +535,99 @@ func getLinks(r io.Reader) []string {
 	}
 
 	traverse(doc)
+	// <meta name="robots" content="nofollow"> asks not to follow the links
+	// of the page, but what it embeds is still needed to display it.
+	if nofollow {
+		links = slices.DeleteFunc(links, func(l pageLink) bool { return !l.requisite })
+	}
 
-	links = append(links, utils.ExtractURLs(state.GetBaseUrl(), content)...)
+	// CSS url() and @import, in stylesheets and style attributes.
+	for _, u := range utils.ExtractURLs(state.GetBaseUrl(), content) {
+		links = append(links, pageLink{u, true})
+	}
 	return links
 }
 
+func isRequisite(n *html.Node) bool {
+	if n.Data == "link" {
+		return slices.ContainsFunc(strings.Fields(getAttr(n, "rel")), func(rel string) bool {
+			return slices.Contains(requisiteRels, strings.ToLower(rel))
+		})
+	}
+	return slices.Contains(requisiteTags, n.Data)
+}
+
+// srcsetURLs returns the URLs of the candidates of a srcset, such as
+// "small.jpg 480w, large.jpg 1080w".
+func srcsetURLs(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// convertSrcset converts the URL of every srcset candidate, keeping their
+// descriptors.
+func convertSrcset(srcset string, convert func(string) string) string {
+	var candidates []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = convert(fields[0])
+		candidates = append(candidates, strings.Join(fields, " "))
+	}
+	return strings.Join(candidates, ", ")
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func isNofollowMeta(n *html.Node) bool {
+	if n.Data != "meta" || !strings.EqualFold(getAttr(n, "name"), "robots") {
+		return false
+	}
+	for _, directive := range strings.Split(getAttr(n, "content"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "nofollow" || directive == "none" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasNofollowRel(n *html.Node) bool {
+	for _, rel := range strings.Fields(getAttr(n, "rel")) {
+		if strings.EqualFold(rel, "nofollow") {
+			return true
+		}
+	}
+	return false
+}
+
+// decodedFile undoes the Content-Encoding of a file saved with
+// --compression=none, so that links are always extracted from HTML.
+func decodedFile(f *os.File, encoding string) io.Reader {
+	if encoding == "" || f == nil {
+		return f
+	}
+	r, err := net.NewDecoder(f, encoding)
+	if err != nil {
+		return bytes.NewReader(nil)
+	}
+	return r
+}
+
 func isLinkAttribute(attr string) bool {
 	linkAttributes := []string{"src", "href", "data", "poster"}
 	for _, a := range linkAttributes {
@@ -311,34 +638,69 @@ func isLinkAttribute(attr string) bool {
 	return false
 }
 
+// processLinks mirrors the links of the site. Whoever adds a link counts it
+// in wg first, so that the crawl can't look finished while a link is handed
+// over.
 func processLinks(p *mpb.Progress, wg *sync.WaitGroup) {
 	baseUrl := state.GetBaseUrl()
 	for link := range state.GetStates().Mirror.Links {
 		absoluteLink := utils.ResolveLink(baseUrl, link)
+		linkUrl, err := url.Parse(absoluteLink)
 
-		if absoluteLink != "" && utils.IsSameDomain(baseUrl, absoluteLink) {
-			wg.Add(1)
+		if absoluteLink != "" && err == nil && net.InScope(linkUrl) {
 			go func(link string) {
 				mirror(p, link)
 			}(absoluteLink)
+		} else {
+			wg.Done()
 		}
 	}
 }
 
 func ExtractURLs(wg *sync.WaitGroup) {
 	for e := range state.GetStates().Mirror.ReadyToExtract {
-		f, _ := os.Open(e.Path)
-		links := getLinks(f)
+		var links []pageLink
+		var f *os.File
+		if e.Content != nil {
+			links = getLinks(bytes.NewReader(e.Content))
+		} else {
+			f, _ = os.Open(e.Path)
+			links = getLinks(decodedFile(f, e.Encoding))
+		}
 
-		for _, l := range links {
-			l = utils.ResolveLink(state.GetBaseUrl(), l)
+		depth, _ := state.GetDepth(e.Url.String())
+		level := *flag.GetFlagValue(flag.LEVEL_FLAG).(*int)
+
+		for _, link := range links {
+			// Links are relative to the page they are found on.
+			l := utils.ResolveLink(e.Url, link.url)
+			if flag.Provided(flag.SPIDER_FLAG) {
+				state.AddReferrer(l, e.Url.String())
+			}
+			// Page requisites are fetched from any host and at any
+			// level. Without --mirror, they are all that is fetched.
+			requisite := link.requisite && flag.Provided(flag.PAGE_REQUISITES_FLAG)
+			if !requisite && (!flag.Recursive() || level > 0 && depth+1 > level) {
+				continue
+			}
+			if requisite {
+				state.AddRequisite(l)
+			}
 			_, loaded := state.GetVisitedLinks().Load(l)
 			if !loaded {
+				state.SetDepth(l, depth+1)
+				wg.Add(1)
 				state.AddLink(l)
 			}
 
 		}
 
+		if e.Content != nil {
+			wg.Done()
+			continue
+		}
+		state.MapUrlPath(e)
+
 		if _, err := html.Parse(f); err != nil {
 			wg.Done()
 			continue
@@ -354,6 +716,8 @@ func handleAbort(wg *sync.WaitGroup) {
 	}
 }
 
+// dirIgnored reports whether the directory of s is out of the crawl: it is
+// excluded with -X, or -I is given and doesn't include it. -X wins.
 func dirIgnored(s string) bool {
 	parsedUrl, _ := url.Parse(s)
 	dirToIgnore := *flag.GetFlagValue(flag.EXCLUDE_FLAG).(*[]string)
@@ -362,5 +726,14 @@ func dirIgnored(s string) bool {
 			return true
 		}
 	}
-	return false
+	dirToInclude := *flag.GetFlagValue(flag.INCLUDE_FLAG).(*[]string)
+	if len(dirToInclude) == 0 {
+		return false
+	}
+	for _, includedDir := range dirToInclude {
+		if utils.PathHasDir(includedDir, parsedUrl.Path) {
+			return false
+		}
+	}
+	return true
 }
@@ -2,12 +2,21 @@ package flag
 
 import (
 	"bufio"
+	"crypto"
+	"encoding/hex"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 	"wget/utils"
+
+	"golang.org/x/term"
 )
 
 type Flag = int
@@ -25,6 +34,65 @@ const (
 	REJECT_FLAG
 	URLS_FLAG
 	EXCLUDE_FLAG
+	CONTINUE_FLAG
+	SEGMENTS_FLAG
+	TRIES_FLAG
+	WAITRETRY_FLAG
+	TIMEOUT_FLAG
+	CONNECT_TIMEOUT_FLAG
+	READ_TIMEOUT_FLAG
+	MAX_CONNS_FLAG
+	HEADER_FLAG
+	HEADER_ENV_FLAG
+	USER_AGENT_FLAG
+	REFERER_FLAG
+	USER_FLAG
+	PASSWORD_FLAG
+	ASK_PASSWORD_FLAG
+	LOAD_COOKIES_FLAG
+	SAVE_COOKIES_FLAG
+	KEEP_SESSION_COOKIES_FLAG
+	PROXY_FLAG
+	NO_PROXY_FLAG
+	PROXY_USER_FLAG
+	PROXY_PASSWORD_FLAG
+	CA_CERTIFICATE_FLAG
+	CA_DIRECTORY_FLAG
+	CERTIFICATE_FLAG
+	PRIVATE_KEY_FLAG
+	PINNED_PUBKEY_FLAG
+	MIN_TLS_FLAG
+	NO_CHECK_CERTIFICATE_FLAG
+	FSYNC_FLAG
+	NO_CLOBBER_FLAG
+	OVERWRITE_FLAG
+	BACKUPS_FLAG
+	TIMESTAMPING_FLAG
+	CHECKSUM_FLAG
+	PRINT_DIGEST_FLAG
+	COMPRESSION_FLAG
+	MAX_REDIRECT_FLAG
+	NO_CROSS_HOST_REDIRECTS_FLAG
+	NO_HTTPS_DOWNGRADE_FLAG
+	TRUST_SERVER_NAMES_FLAG
+	SPIDER_FLAG
+	LEVEL_FLAG
+	MAX_PAGES_FLAG
+	QUOTA_FLAG
+	MAX_DURATION_FLAG
+	EXECUTE_FLAG
+	SITEMAP_FLAG
+	SPAN_HOSTS_FLAG
+	DOMAINS_FLAG
+	EXCLUDE_DOMAINS_FLAG
+	PAGE_REQUISITES_FLAG
+	ACCEPT_FLAG
+	ACCEPT_REGEX_FLAG
+	REJECT_REGEX_FLAG
+	INCLUDE_FLAG
+	NO_FOLLOW_REJECTED_FLAG
+	NO_PARENT_FLAG
+	SCOPE_FLAG
 )
 
 var (
@@ -41,9 +109,77 @@ var (
 	Mirror      = new(bool)
 	Reject      = new([]string)
 	Excludes    = new([]string)
+	Continue    = new(bool)
+	Segments    = new(int)
+	Tries       = new(int)
+	WaitRetry   = new(int)
+	Timeout     = new(float64)
+	ConnTimeout = new(float64)
+	ReadTimeout = new(float64)
+	MaxConns    = new(int)
+	Headers     = new([]string)
+	HeaderEnvs  = new([]string)
+	UserAgent   = new(string)
+	Referer     = new(string)
+	User        = new(string)
+	Password    = new(string)
+	AskPassword = new(bool)
+	LoadCookies = new(string)
+	SaveCookies = new(string)
+	KeepSession = new(bool)
+	Proxy       = new(string)
+	NoProxy     = new(bool)
+	ProxyUser   = new(string)
+	ProxyPass   = new(string)
+	CACert      = new(string)
+	CADir       = new(string)
+	Cert        = new(string)
+	PrivateKey  = new(string)
+	PinnedKey   = new(string)
+	MinTLS      = new(string)
+	NoCheckCert = new(bool)
+	Fsync       = new(bool)
+	NoClobber   = new(bool)
+	Overwrite   = new(bool)
+	Backups     = new(int)
+	Timestamp   = new(bool)
+	Checksum    = new(string)
+	PrintDigest = new(string)
+	Compression = new(string)
+	MaxRedirect = new(int)
+	NoCrossHost = new(bool)
+	NoDowngrade = new(bool)
+	TrustNames  = new(bool)
+	Spider      = new(bool)
+	Level       = new(int)
+	MaxPages    = new(int)
+	Quota       = new(string)
+	MaxDuration = new(time.Duration)
+	Execute     = new([]string)
+	Sitemap     = new(string)
+	SpanHosts   = new(bool)
+	Domains     = new([]string)
+	ExclDomains = new([]string)
+	PageReqs    = new(bool)
+	Accept      = new([]string)
+	AcceptRegex = new(string)
+	RejectRegex = new(string)
+	Includes    = new([]string)
+	NoFollowRej = new(bool)
+	NoParent    = new(bool)
+	Scope       = new(string)
+	acceptRegex *regexp.Regexp
+	rejectRegex *regexp.Regexp
+	quota       int64
+	robots      = true
+	checksums   = map[string]string{}
+	headers     = http.Header{}
 	flagNames   = make(map[Flag]string)
 )
 
+// PASSWORD_ENV carries the --ask-password answer to the background child.
+const PASSWORD_ENV = "WGET_PASSWORD"
+
 var flagsValues = map[int]any{}
 
 func Provided(flagName Flag) bool {
@@ -53,6 +189,18 @@ func Provided(flagName Flag) bool {
 	if v, ok := flagsValues[flagName].(*bool); ok {
 		return *v
 	}
+	if v, ok := flagsValues[flagName].(*int); ok {
+		return *v > 0
+	}
+	if v, ok := flagsValues[flagName].(*float64); ok {
+		return *v > 0
+	}
+	if v, ok := flagsValues[flagName].(*time.Duration); ok {
+		return *v > 0
+	}
+	if v, ok := flagsValues[flagName].(*[]string); ok {
+		return len(*v) > 0
+	}
 	return false
 }
 
@@ -77,6 +225,65 @@ func SetupFlagName() {
 	flagNames[REJECT_FLAG] = "reject"
 	flagNames[EXCLUDE_FLAG] = "exclude"
 	flagNames[CONVERT_FLAG] = "convert-links"
+	flagNames[CONTINUE_FLAG] = "continue"
+	flagNames[SEGMENTS_FLAG] = "segments"
+	flagNames[TRIES_FLAG] = "tries"
+	flagNames[WAITRETRY_FLAG] = "waitretry"
+	flagNames[TIMEOUT_FLAG] = "timeout"
+	flagNames[CONNECT_TIMEOUT_FLAG] = "connect-timeout"
+	flagNames[READ_TIMEOUT_FLAG] = "read-timeout"
+	flagNames[MAX_CONNS_FLAG] = "max-conns-per-host"
+	flagNames[HEADER_FLAG] = "header"
+	flagNames[HEADER_ENV_FLAG] = "header-env"
+	flagNames[USER_AGENT_FLAG] = "user-agent"
+	flagNames[REFERER_FLAG] = "referer"
+	flagNames[USER_FLAG] = "user"
+	flagNames[PASSWORD_FLAG] = "password"
+	flagNames[ASK_PASSWORD_FLAG] = "ask-password"
+	flagNames[LOAD_COOKIES_FLAG] = "load-cookies"
+	flagNames[SAVE_COOKIES_FLAG] = "save-cookies"
+	flagNames[KEEP_SESSION_COOKIES_FLAG] = "keep-session-cookies"
+	flagNames[PROXY_FLAG] = "proxy"
+	flagNames[NO_PROXY_FLAG] = "no-proxy"
+	flagNames[PROXY_USER_FLAG] = "proxy-user"
+	flagNames[PROXY_PASSWORD_FLAG] = "proxy-password"
+	flagNames[CA_CERTIFICATE_FLAG] = "ca-certificate"
+	flagNames[CA_DIRECTORY_FLAG] = "ca-directory"
+	flagNames[CERTIFICATE_FLAG] = "certificate"
+	flagNames[PRIVATE_KEY_FLAG] = "private-key"
+	flagNames[PINNED_PUBKEY_FLAG] = "pinnedpubkey"
+	flagNames[MIN_TLS_FLAG] = "min-tls-version"
+	flagNames[NO_CHECK_CERTIFICATE_FLAG] = "no-check-certificate"
+	flagNames[FSYNC_FLAG] = "fsync"
+	flagNames[NO_CLOBBER_FLAG] = "no-clobber"
+	flagNames[OVERWRITE_FLAG] = "overwrite"
+	flagNames[BACKUPS_FLAG] = "backups"
+	flagNames[TIMESTAMPING_FLAG] = "timestamping"
+	flagNames[CHECKSUM_FLAG] = "checksum"
+	flagNames[PRINT_DIGEST_FLAG] = "print-digest"
+	flagNames[COMPRESSION_FLAG] = "compression"
+	flagNames[MAX_REDIRECT_FLAG] = "max-redirect"
+	flagNames[NO_CROSS_HOST_REDIRECTS_FLAG] = "no-cross-host-redirects"
+	flagNames[NO_HTTPS_DOWNGRADE_FLAG] = "no-https-downgrade"
+	flagNames[TRUST_SERVER_NAMES_FLAG] = "trust-server-names"
+	flagNames[SPIDER_FLAG] = "spider"
+	flagNames[LEVEL_FLAG] = "level"
+	flagNames[MAX_PAGES_FLAG] = "max-pages"
+	flagNames[QUOTA_FLAG] = "quota"
+	flagNames[MAX_DURATION_FLAG] = "max-duration"
+	flagNames[EXECUTE_FLAG] = "execute"
+	flagNames[SITEMAP_FLAG] = "sitemap"
+	flagNames[SPAN_HOSTS_FLAG] = "span-hosts"
+	flagNames[DOMAINS_FLAG] = "domains"
+	flagNames[EXCLUDE_DOMAINS_FLAG] = "exclude-domains"
+	flagNames[PAGE_REQUISITES_FLAG] = "page-requisites"
+	flagNames[ACCEPT_FLAG] = "accept"
+	flagNames[ACCEPT_REGEX_FLAG] = "accept-regex"
+	flagNames[REJECT_REGEX_FLAG] = "reject-regex"
+	flagNames[INCLUDE_FLAG] = "include-directories"
+	flagNames[NO_FOLLOW_REJECTED_FLAG] = "no-follow-rejected"
+	flagNames[NO_PARENT_FLAG] = "no-parent"
+	flagNames[SCOPE_FLAG] = "scope"
 
 }
 
@@ -88,6 +295,65 @@ func InitFlagValues() {
 	flagsValues[REJECT_FLAG] = Reject
 	flagsValues[EXCLUDE_FLAG] = Excludes
 	flagsValues[CONVERT_FLAG] = Convert
+	flagsValues[CONTINUE_FLAG] = Continue
+	flagsValues[SEGMENTS_FLAG] = Segments
+	flagsValues[TRIES_FLAG] = Tries
+	flagsValues[WAITRETRY_FLAG] = WaitRetry
+	flagsValues[TIMEOUT_FLAG] = Timeout
+	flagsValues[CONNECT_TIMEOUT_FLAG] = ConnTimeout
+	flagsValues[READ_TIMEOUT_FLAG] = ReadTimeout
+	flagsValues[MAX_CONNS_FLAG] = MaxConns
+	flagsValues[HEADER_FLAG] = Headers
+	flagsValues[HEADER_ENV_FLAG] = HeaderEnvs
+	flagsValues[USER_AGENT_FLAG] = UserAgent
+	flagsValues[REFERER_FLAG] = Referer
+	flagsValues[USER_FLAG] = User
+	flagsValues[PASSWORD_FLAG] = Password
+	flagsValues[ASK_PASSWORD_FLAG] = AskPassword
+	flagsValues[LOAD_COOKIES_FLAG] = LoadCookies
+	flagsValues[SAVE_COOKIES_FLAG] = SaveCookies
+	flagsValues[KEEP_SESSION_COOKIES_FLAG] = KeepSession
+	flagsValues[PROXY_FLAG] = Proxy
+	flagsValues[NO_PROXY_FLAG] = NoProxy
+	flagsValues[PROXY_USER_FLAG] = ProxyUser
+	flagsValues[PROXY_PASSWORD_FLAG] = ProxyPass
+	flagsValues[CA_CERTIFICATE_FLAG] = CACert
+	flagsValues[CA_DIRECTORY_FLAG] = CADir
+	flagsValues[CERTIFICATE_FLAG] = Cert
+	flagsValues[PRIVATE_KEY_FLAG] = PrivateKey
+	flagsValues[PINNED_PUBKEY_FLAG] = PinnedKey
+	flagsValues[MIN_TLS_FLAG] = MinTLS
+	flagsValues[NO_CHECK_CERTIFICATE_FLAG] = NoCheckCert
+	flagsValues[FSYNC_FLAG] = Fsync
+	flagsValues[NO_CLOBBER_FLAG] = NoClobber
+	flagsValues[OVERWRITE_FLAG] = Overwrite
+	flagsValues[BACKUPS_FLAG] = Backups
+	flagsValues[TIMESTAMPING_FLAG] = Timestamp
+	flagsValues[CHECKSUM_FLAG] = Checksum
+	flagsValues[PRINT_DIGEST_FLAG] = PrintDigest
+	flagsValues[COMPRESSION_FLAG] = Compression
+	flagsValues[MAX_REDIRECT_FLAG] = MaxRedirect
+	flagsValues[NO_CROSS_HOST_REDIRECTS_FLAG] = NoCrossHost
+	flagsValues[NO_HTTPS_DOWNGRADE_FLAG] = NoDowngrade
+	flagsValues[TRUST_SERVER_NAMES_FLAG] = TrustNames
+	flagsValues[SPIDER_FLAG] = Spider
+	flagsValues[LEVEL_FLAG] = Level
+	flagsValues[MAX_PAGES_FLAG] = MaxPages
+	flagsValues[QUOTA_FLAG] = Quota
+	flagsValues[MAX_DURATION_FLAG] = MaxDuration
+	flagsValues[EXECUTE_FLAG] = Execute
+	flagsValues[SITEMAP_FLAG] = Sitemap
+	flagsValues[SPAN_HOSTS_FLAG] = SpanHosts
+	flagsValues[DOMAINS_FLAG] = Domains
+	flagsValues[EXCLUDE_DOMAINS_FLAG] = ExclDomains
+	flagsValues[PAGE_REQUISITES_FLAG] = PageReqs
+	flagsValues[ACCEPT_FLAG] = Accept
+	flagsValues[ACCEPT_REGEX_FLAG] = AcceptRegex
+	flagsValues[REJECT_REGEX_FLAG] = RejectRegex
+	flagsValues[INCLUDE_FLAG] = Includes
+	flagsValues[NO_FOLLOW_REJECTED_FLAG] = NoFollowRej
+	flagsValues[NO_PARENT_FLAG] = NoParent
+	flagsValues[SCOPE_FLAG] = Scope
 
 	limited := *RateLimit != ""
 
@@ -166,19 +432,31 @@ func SetupUrls(args []string) {
 		scanner := bufio.NewScanner(file)
 
 		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line == "" {
+			// A line is a URL, optionally followed by its algo:hex checksum.
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 {
 				continue
 			}
+			line := fields[0]
 			parsedURL, err := url.Parse(line)
 			if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
 				os.Stderr.Write([]byte(fmt.Sprintf("Invalid url: %s\n", line)))
 				continue
 			}
+			if len(fields) > 1 {
+				if _, _, err := ParseChecksum(fields[1]); err != nil {
+					os.Stderr.Write([]byte(fmt.Sprintf("Invalid checksum for %s: %v\n", line, err)))
+					continue
+				}
+				checksums[line] = fields[1]
+			}
 			u = append(u, line)
 		}
 	} else {
 		u = append(u, args[0])
+		if *Checksum != "" {
+			checksums[args[0]] = *Checksum
+		}
 	}
 
 	if len(u) == 0 {
@@ -208,22 +486,346 @@ func GetArgs() []string {
 	return a
 }
 
+// IsMirror reports whether pages are saved under their host directory and
+// their links extracted, as --mirror and --page-requisites do.
 func IsMirror() bool {
-	return *Mirror
+	return *Mirror || *PageReqs
 }
 
-func SetOutputPath(path string) {
-	flagsValues[PATH_FLAG] = &path
+// Recursive reports whether navigation links are followed, which only
+// --mirror does.
+func Recursive() bool {
+	return *Mirror
 }
 
 func CheckFlags() error {
-	if Provided(REJECT_FLAG) || Provided(EXCLUDE_FLAG) || Provided(CONVERT_FLAG) && !*Mirror {
+	if (Provided(REJECT_FLAG) || Provided(EXCLUDE_FLAG) || Provided(CONVERT_FLAG) || Provided(ACCEPT_FLAG) || Provided(INCLUDE_FLAG) || *AcceptRegex != "" || *RejectRegex != "" || *NoFollowRej) && !IsMirror() {
+		return fmt.Errorf("should specify mirror flag: --mirror")
+	}
+
+	var err error
+	if acceptRegex, err = compileRegex(*AcceptRegex); err != nil {
+		return fmt.Errorf("invalid accept-regex: %v", err)
+	}
+	if rejectRegex, err = compileRegex(*RejectRegex); err != nil {
+		return fmt.Errorf("invalid reject-regex: %v", err)
+	}
+
+	if *Segments < 0 {
+		return fmt.Errorf("invalid number of segments: %d", *Segments)
+	}
+
+	if *Tries < 0 {
+		return fmt.Errorf("invalid number of tries: %d", *Tries)
+	}
+
+	if *WaitRetry < 0 {
+		return fmt.Errorf("invalid waitretry: %d", *WaitRetry)
+	}
+
+	if *Timeout < 0 || *ConnTimeout < 0 || *ReadTimeout < 0 {
+		return fmt.Errorf("timeouts cannot be negative")
+	}
+
+	if *MaxConns < 0 {
+		return fmt.Errorf("invalid max-conns-per-host: %d", *MaxConns)
+	}
+
+	if *Level < 0 || *MaxPages < 0 || *MaxDuration < 0 {
+		return fmt.Errorf("level, max-pages and max-duration cannot be negative")
+	}
+
+	if *Quota != "" {
+		size, err := parseSize(*Quota)
+		if err != nil {
+			return fmt.Errorf("invalid quota %q. usage: --quota 500M", *Quota)
+		}
+		quota = size
+	}
+
+	if *Sitemap != "" {
+		if _, err := url.Parse(*Sitemap); err != nil {
+			return fmt.Errorf("invalid sitemap URL %q", *Sitemap)
+		}
+	}
+
+	if (*Level > 0 || *MaxPages > 0 || *Quota != "" || *MaxDuration > 0 || *Sitemap != "") && !*Mirror {
 		return fmt.Errorf("should specify mirror flag: --mirror")
 	}
 
-	if *Mirror && Provided(INPUT_FLAG) {
+	if (*SpanHosts || len(*Domains) > 0 || len(*ExclDomains) > 0 || *NoParent || *Scope != "") && !*Mirror {
+		return fmt.Errorf("should specify mirror flag: --mirror")
+	}
+
+	if !slices.Contains([]string{"", "host", "directory", "prefix"}, *Scope) {
+		return fmt.Errorf("invalid scope %q. usage: --scope host|directory|prefix", *Scope)
+	}
+
+	if *NoParent && *Scope != "" && *Scope != "directory" {
+		return fmt.Errorf("no-parent and scope %s cannot go alongside", *Scope)
+	}
+	for _, list := range []*[]string{Domains, ExclDomains} {
+		for i, domain := range *list {
+			domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+			if domain == "" {
+				return fmt.Errorf("invalid empty domain. usage: --domains example.com,cdn.net")
+			}
+			(*list)[i] = domain
+		}
+	}
+
+	if *Spider && *Convert {
+		return fmt.Errorf("spider and convert-links cannot go alongside")
+	}
+
+	if *MaxRedirect < 0 {
+		return fmt.Errorf("invalid max-redirect: %d", *MaxRedirect)
+	}
+
+	if *Backups < 0 {
+		return fmt.Errorf("invalid number of backups: %d", *Backups)
+	}
+
+	if *NoClobber && (*Overwrite || *Backups > 0) {
+		return fmt.Errorf("no-clobber cannot go alongside overwrite or backups")
+	}
+
+	if *NoClobber && *Timestamp {
+		return fmt.Errorf("no-clobber and timestamping cannot go alongside")
+	}
+
+	if err := setupCommands(); err != nil {
+		return err
+	}
+
+	if err := setupHeaders(); err != nil {
+		return err
+	}
+
+	if err := setupPassword(); err != nil {
+		return err
+	}
+
+	if *Proxy != "" {
+		if *NoProxy {
+			return fmt.Errorf("proxy and no-proxy cannot go alongside")
+		}
+		proxyURL, err := url.Parse(*Proxy)
+		if err != nil || proxyURL.Host == "" || !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, proxyURL.Scheme) {
+			return fmt.Errorf("invalid proxy %q. usage: --proxy http://host:port or socks5://host:port", *Proxy)
+		}
+	}
+
+	if IsMirror() && Provided(INPUT_FLAG) {
 		return fmt.Errorf("mirror and input cannot go alongside")
 	}
 
+	if *Checksum != "" {
+		if Provided(INPUT_FLAG) {
+			return fmt.Errorf("checksum and input cannot go alongside. add the checksums to the input file instead")
+		}
+		if _, _, err := ParseChecksum(*Checksum); err != nil {
+			return err
+		}
+	}
+
+	if !slices.Contains([]string{"auto", "gzip", "none"}, *Compression) {
+		return fmt.Errorf("invalid compression %q. usage: --compression auto|gzip|none", *Compression)
+	}
+
+	if _, ok := ChecksumHash(*PrintDigest); *PrintDigest != "" && !ok {
+		return fmt.Errorf("unsupported print-digest algorithm %q", *PrintDigest)
+	}
+
+	return nil
+}
+
+// setupHeaders parses the --header "Name: value" and --header-env
+// "Name=VARIABLE" flags into the headers added to every request.
+func setupHeaders() error {
+	for _, h := range *Headers {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return fmt.Errorf("invalid header %q. usage: --header \"Name: value\"", h)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+
+	for _, h := range *HeaderEnvs {
+		name, variable, ok := strings.Cut(h, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || variable == "" {
+			return fmt.Errorf("invalid header-env %q. usage: --header-env Name=VARIABLE", h)
+		}
+		value, ok := os.LookupEnv(variable)
+		if !ok {
+			return fmt.Errorf("environment variable %s is not set for header %s", variable, name)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+
+	return nil
+}
+
+// setupCommands applies the -e commands, written as in a .wgetrc. Only
+// robots=on|off is supported.
+func setupCommands() error {
+	for _, c := range *Execute {
+		name, value, _ := strings.Cut(c, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if name != "robots" || (value != "on" && value != "off") {
+			return fmt.Errorf("unknown command %q. usage: -e robots=off", c)
+		}
+		robots = value == "on"
+	}
+	return nil
+}
+
+// Robots reports whether the mirror crawler follows robots.txt and the
+// nofollow hints of pages.
+func Robots() bool {
+	return robots
+}
+
+// setupPassword prompts for the password when --ask-password is given. A
+// background child can't prompt, so it receives the password from its parent
+// through the environment.
+func setupPassword() error {
+	if !*AskPassword {
+		return nil
+	}
+	if *Password != "" {
+		return fmt.Errorf("password and ask-password cannot go alongside")
+	}
+	if *User == "" {
+		return fmt.Errorf("ask-password requires --user")
+	}
+
+	if password, ok := os.LookupEnv(PASSWORD_ENV); ok {
+		*Password = password
+		return nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("cannot read password: %v", err)
+		}
+		*Password = strings.TrimRight(line, "\r\n")
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for user %s: ", *User)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("cannot read password: %v", err)
+	}
+	*Password = string(password)
 	return nil
 }
+
+func GetHeaders() http.Header {
+	return headers
+}
+
+var checksumHashes = map[string]crypto.Hash{
+	"md5":    crypto.MD5,
+	"sha1":   crypto.SHA1,
+	"sha256": crypto.SHA256,
+	"sha512": crypto.SHA512,
+}
+
+// ChecksumHash returns the hash named by a --checksum or --print-digest
+// algorithm.
+func ChecksumHash(algo string) (crypto.Hash, bool) {
+	h, ok := checksumHashes[strings.ToLower(algo)]
+	return h, ok
+}
+
+// ParseChecksum parses an algo:hex checksum such as sha256:9f86d0...
+func ParseChecksum(spec string) (crypto.Hash, []byte, error) {
+	algo, sum, _ := strings.Cut(spec, ":")
+	h, ok := ChecksumHash(algo)
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid checksum %q. usage: --checksum sha256:<hex> (md5, sha1, sha256 or sha512)", spec)
+	}
+	b, err := hex.DecodeString(sum)
+	if err != nil || len(b) != h.Size() {
+		return 0, nil, fmt.Errorf("invalid %s checksum %q", algo, sum)
+	}
+	return h, b, nil
+}
+
+// parseSize reads a number of bytes, optionally followed by k, m or g.
+func parseSize(s string) (int64, error) {
+	multiplier := int64(1)
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "k":
+		multiplier = 1024
+	case "m":
+		multiplier = 1024 * 1024
+	case "g":
+		multiplier = 1024 * 1024 * 1024
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// GetQuota returns the --quota in bytes, or 0 when there is none.
+func GetQuota() int64 {
+	return quota
+}
+
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// GetAcceptRegex returns the compiled --accept-regex, or nil.
+func GetAcceptRegex() *regexp.Regexp {
+	return acceptRegex
+}
+
+// GetRejectRegex returns the compiled --reject-regex, or nil.
+func GetRejectRegex() *regexp.Regexp {
+	return rejectRegex
+}
+
+// GetScope returns the part of the first page's host that a mirror crawls:
+// host, directory (the default with --no-parent) or prefix.
+func GetScope() string {
+	if *NoParent {
+		return "directory"
+	}
+	if *Scope == "" {
+		return "host"
+	}
+	return *Scope
+}
+
+// GetDomains returns the --domains list, lower-cased.
+func GetDomains() []string {
+	return *Domains
+}
+
+// GetExcludedDomains returns the --exclude-domains list, lower-cased.
+func GetExcludedDomains() []string {
+	return *ExclDomains
+}
+
+// GetChecksum returns the checksum expected for u, if one was given.
+func GetChecksum(u string) string {
+	return checksums[u]
+}
@@ -3,6 +3,8 @@ module wget
 go 1.22.2
 
 require (
+	github.com/andybalholm/brotli v1.2.0
+	github.com/klauspost/compress v1.18.0
 	github.com/spf13/cobra v1.8.1
 	github.com/vbauerster/mpb v3.4.0+incompatible
 )
@@ -15,6 +17,7 @@ require (
 	golang.org/x/crypto v0.26.0 // indirect
 	golang.org/x/net v0.28.0
 	golang.org/x/sys v0.23.0
-	golang.org/x/term v0.23.0 // indirect
+	golang.org/x/term v0.23.0
+	golang.org/x/text v0.17.0 // indirect
 	golang.org/x/time v0.6.0
 )
@@ -0,0 +1,211 @@
+package net
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"wget/flag"
+	"wget/utils"
+)
+
+// authTransport answers Basic and Digest challenges. A request that comes
+// back 401 is sent again with credentials, and the challenge is remembered
+// so that later requests to the same host authenticate up front.
+type authTransport struct {
+	base       http.RoundTripper
+	challenges sync.Map // host -> *challenge
+}
+
+type challenge struct {
+	scheme string
+	params map[string]string
+	nc     atomic.Uint32
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	user, password, ok := credentialsFor(req.URL)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	if c, ok := t.challenges.Load(req.URL.Host); ok {
+		resp, err := t.base.RoundTrip(authorize(req, c.(*challenge), user, password))
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		// The nonce went stale or the server changed its mind: start over
+		// from the new challenge.
+		return t.answer(req, resp, user, password)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	return t.answer(req, resp, user, password)
+}
+
+func (t *authTransport) answer(req *http.Request, resp *http.Response, user string, password string) (*http.Response, error) {
+	c := parseChallenge(resp.Header.Values("WWW-Authenticate"))
+	if c == nil {
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	t.challenges.Store(req.URL.Host, c)
+	return t.base.RoundTrip(authorize(req, c, user, password))
+}
+
+// credentialsFor returns the credentials to offer to u's host. --user and
+// --password are only ever sent to the hosts of the URLs given on the command
+// line, as defined by utils.IsSameDomain, so that a mirror crawl or a
+// redirect can't leak them elsewhere. ~/.netrc entries are per host already.
+func credentialsFor(u *url.URL) (string, string, bool) {
+	trusted := false
+	for _, start := range flag.GetUrls() {
+		if startURL, err := url.Parse(start); err == nil && utils.IsSameDomain(startURL, u.String()) {
+			trusted = true
+			break
+		}
+	}
+
+	if trusted && flag.Provided(flag.USER_FLAG) {
+		return *flag.GetFlagValue(flag.USER_FLAG).(*string), *flag.GetFlagValue(flag.PASSWORD_FLAG).(*string), true
+	}
+	return netrcLookup(u.Hostname(), trusted)
+}
+
+// parseChallenge picks the strongest challenge the server offered, preferring
+// Digest over Basic.
+func parseChallenge(headers []string) *challenge {
+	var basic *challenge
+	for _, h := range headers {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(h), " ")
+		switch strings.ToLower(scheme) {
+		case "digest":
+			return &challenge{scheme: "digest", params: parseAuthParams(rest)}
+		case "basic":
+			basic = &challenge{scheme: "basic", params: parseAuthParams(rest)}
+		}
+	}
+	return basic
+}
+
+// parseAuthParams splits `realm="x", nonce="y", qop=auth` into a map, keeping
+// commas that appear inside quoted values.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			value = strings.ReplaceAll(rest[1:min(end, len(rest))], `\`, "")
+			s = rest[min(end+1, len(rest)):]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// authorize returns a copy of req carrying the answer to c.
+func authorize(req *http.Request, c *challenge, user string, password string) *http.Request {
+	r := req.Clone(req.Context())
+	if c.scheme == "basic" {
+		r.SetBasicAuth(user, password)
+		return r
+	}
+	r.Header.Set("Authorization", digestAuthorization(r, c, user, password))
+	return r
+}
+
+func digestAuthorization(req *http.Request, c *challenge, user string, password string) string {
+	algorithm := c.params["algorithm"]
+	var newHash func() hash.Hash = md5.New
+	if strings.HasPrefix(strings.ToUpper(algorithm), "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(s string) string {
+		sum := newHash()
+		io.WriteString(sum, s)
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	realm, nonce := c.params["realm"], c.params["nonce"]
+	uri := req.URL.RequestURI()
+	cnonce := newCnonce()
+	nc := fmt.Sprintf("%08x", c.nc.Add(1))
+
+	ha1 := h(user + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+
+	qop := ""
+	for _, q := range strings.Split(c.params["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	var response string
+	if qop != "" {
+		response = h(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, user),
+		fmt.Sprintf(`realm="%s"`, realm),
+		fmt.Sprintf(`nonce="%s"`, nonce),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if algorithm != "" {
+		fields = append(fields, "algorithm="+algorithm)
+	}
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if opaque, ok := c.params["opaque"]; ok {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+	return "Digest " + strings.Join(fields, ", ")
+}
+
+func newCnonce() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,167 @@
+package net
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"wget/flag"
+)
+
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// digestAlgorithms maps the algorithm names of the Digest and Content-Digest
+// headers to their hash.
+var digestAlgorithms = map[string]crypto.Hash{
+	"md5":     crypto.MD5,
+	"sha":     crypto.SHA1,
+	"sha-256": crypto.SHA256,
+	"sha-512": crypto.SHA512,
+}
+
+var hashNames = map[crypto.Hash]string{
+	crypto.MD5:    "md5",
+	crypto.SHA1:   "sha1",
+	crypto.SHA256: "sha256",
+	crypto.SHA512: "sha512",
+}
+
+type checksum struct {
+	hash   crypto.Hash
+	sum    []byte
+	source string
+}
+
+// digests hashes a download while it is written, to check it against the
+// expected checksums and print its digest once it is complete.
+type digests struct {
+	expected []checksum
+	print    crypto.Hash
+	hashes   map[crypto.Hash]hash.Hash
+	written  int64
+}
+
+// newDigests collects the checksums expected for the download: the one given
+// with --checksum or in the input file, and those the server sent along with
+// resp. It returns nil when there is nothing to compute.
+func (d *download) newDigests(resp *http.Response) *digests {
+	g := &digests{hashes: map[crypto.Hash]hash.Hash{}}
+
+	if spec := flag.GetChecksum(d.url); spec != "" {
+		h, sum, _ := flag.ParseChecksum(spec)
+		g.expected = append(g.expected, checksum{h, sum, "--checksum"})
+	}
+	if resp != nil && !d.decoded {
+		g.expected = append(g.expected, headerDigests(resp)...)
+	}
+	if h, ok := flag.ChecksumHash(*flag.GetFlagValue(flag.PRINT_DIGEST_FLAG).(*string)); ok {
+		g.print = h
+		g.hashes[h] = h.New()
+	}
+
+	for _, c := range g.expected {
+		if _, ok := g.hashes[c.hash]; !ok {
+			g.hashes[c.hash] = c.hash.New()
+		}
+	}
+	if len(g.hashes) == 0 {
+		return nil
+	}
+	return g
+}
+
+// headerDigests reads the Digest (RFC 3230) and Content-Digest (RFC 9530)
+// headers. They describe the whole body as sent, so they are only used for a
+// complete response that is saved without decoding it.
+func headerDigests(resp *http.Response) []checksum {
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var checksums []checksum
+	for _, name := range []string{"Content-Digest", "Digest"} {
+		for _, field := range strings.Split(strings.Join(resp.Header.Values(name), ","), ",") {
+			algo, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			h, known := digestAlgorithms[strings.ToLower(algo)]
+			if !ok || !known {
+				continue
+			}
+			sum, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+			if err != nil || len(sum) != h.Size() {
+				continue
+			}
+			checksums = append(checksums, checksum{h, sum, name + " header"})
+		}
+	}
+	return checksums
+}
+
+// writer returns w, also feeding everything written to it to the hashes.
+func (g *digests) writer(w io.Writer) io.Writer {
+	if g == nil {
+		return w
+	}
+	return io.MultiWriter(w, g)
+}
+
+// seed hashes the first n bytes already in the file, before a resumed
+// download appends to it.
+func (g *digests) seed(out_file *os.File, n int64) error {
+	if g == nil || n == 0 {
+		return nil
+	}
+	_, err := io.Copy(g, io.NewSectionReader(out_file, 0, n))
+	return err
+}
+
+func (g *digests) Write(p []byte) (int, error) {
+	for _, h := range g.hashes {
+		h.Write(p)
+	}
+	g.written += int64(len(p))
+	return len(p), nil
+}
+
+// verify checks the file against the expected checksums. A file that wasn't
+// streamed through the writer, like one written by segments, is hashed from
+// disk first.
+func (g *digests) verify(out_file *os.File) error {
+	if g == nil {
+		return nil
+	}
+	if g.written == 0 {
+		info, err := out_file.Stat()
+		if err != nil {
+			return err
+		}
+		if err := g.seed(out_file, info.Size()); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range g.expected {
+		if sum := g.hashes[c.hash].Sum(nil); !bytes.Equal(sum, c.sum) {
+			name := hashNames[c.hash]
+			return fmt.Errorf("%w: expected %s:%x from %s, got %s:%x", errChecksumMismatch, name, c.sum, c.source, name, sum)
+		}
+	}
+	return nil
+}
+
+// printDigest prints the --print-digest line of a completed download.
+func (g *digests) printDigest(path string) {
+	if g == nil || g.print == 0 {
+		return
+	}
+	fmt.Printf("%s:%x  %s\n", hashNames[g.print], g.hashes[g.print].Sum(nil), path)
+}
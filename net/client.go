@@ -0,0 +1,101 @@
+package net
+
+import (
+	"context"
+	stdnet "net"
+	"net/http"
+	"sync"
+	"time"
+	"wget/flag"
+)
+
+var (
+	client     *http.Client
+	clientErr  error
+	clientOnce sync.Once
+)
+
+// Client returns the HTTP client shared by every request the program makes:
+// HEAD probes, downloads, segments and mirror crawling. It is built on first
+// use, once the flags have been parsed, so that connections are pooled and
+// every option applies everywhere.
+func Client() *http.Client {
+	clientOnce.Do(func() {
+		client, clientErr = newClient()
+	})
+	return client
+}
+
+// InitClient builds the shared client and reports configuration errors, such
+// as an unreadable CA bundle, before any download starts.
+func InitClient() error {
+	Client()
+	return clientErr
+}
+
+func newClient() (*http.Client, error) {
+	connect, read := getTimeouts()
+	maxConns := *flag.GetFlagValue(flag.MAX_CONNS_FLAG).(*int)
+
+	dialer := &stdnet.Dialer{
+		Timeout:   defaultDialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	if flag.Provided(flag.TIMEOUT_FLAG) || flag.Provided(flag.CONNECT_TIMEOUT_FLAG) {
+		dialer.Timeout = connect
+		transport.TLSHandshakeTimeout = connect
+	}
+	transport.ResponseHeaderTimeout = read
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxConnsPerHost = maxConns
+	transport.MaxIdleConnsPerHost = maxConns
+	// Content-Encoding is handled by the download itself, following
+	// --compression.
+	transport.DisableCompression = true
+	configureProxy(transport, dialer)
+
+	tlsConfig, err := newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport:     &authTransport{base: transport},
+		Jar:           newCookieJar(),
+		CheckRedirect: checkRedirect,
+	}, nil
+}
+
+// NewRequest builds a request carrying the headers every request shares:
+// the User-Agent, the Referer and any --header or --header-env values.
+func NewRequest(ctx context.Context, method string, u string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	setAcceptEncoding(req)
+	if flag.Provided(flag.USER_AGENT_FLAG) {
+		req.Header.Set("User-Agent", *flag.GetFlagValue(flag.USER_AGENT_FLAG).(*string))
+	}
+	if flag.Provided(flag.REFERER_FLAG) {
+		req.Header.Set("Referer", *flag.GetFlagValue(flag.REFERER_FLAG).(*string))
+	}
+
+	for name, values := range flag.GetHeaders() {
+		if name == "Host" {
+			req.Host = values[len(values)-1]
+			continue
+		}
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,102 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"wget/flag"
+)
+
+// errClobber is returned when --no-clobber finds the target already taken.
+var errClobber = errors.New("file already there; not retrieving")
+
+// errSamePath is returned when overwriting and another download of the run
+// already claimed the target, like /dir/ and /dir/index.html in a mirror.
+var errSamePath = errors.New("is saved to the same file as another download; not retrieving")
+
+var (
+	claimedMu sync.Mutex
+	claimed   = map[string]bool{}
+)
+
+// overwrites reports whether an existing file is replaced rather than kept
+// next to a numbered copy. Mirroring and --timestamping overwrite, like
+// --overwrite and --backups do.
+func overwrites() bool {
+	return flag.IsMirror() || flag.Provided(flag.TIMESTAMPING_FLAG) || flag.Provided(flag.OVERWRITE_FLAG) || flag.Provided(flag.BACKUPS_FLAG)
+}
+
+// claimPath settles the final path of the download and reserves it for the
+// rest of the run, so that no two downloads ever write the same file. When
+// the path is taken, either on disk or by another download, the file is
+// saved under the first free numbered name (file.1, file.2, ...), unless
+// --no-clobber says to skip it. Existing files are replaced only when
+// overwriting or resuming, and a path claimed by another download is then
+// skipped rather than numbered.
+func (d *download) claimPath(resume bool) error {
+	claimedMu.Lock()
+	defer claimedMu.Unlock()
+
+	if d.claimed {
+		return nil
+	}
+
+	path := d.path
+	if claimed[path] && overwrites() {
+		return errSamePath
+	}
+	taken := claimed[path] || (!resume && !overwrites() && fileExists(path))
+	if taken {
+		if flag.Provided(flag.NO_CLOBBER_FLAG) || resume {
+			return errClobber
+		}
+		for i := 1; ; i++ {
+			candidate := fmt.Sprintf("%s.%d", d.path, i)
+			if !claimed[candidate] && !fileExists(candidate) && !fileExists(candidate+".part") {
+				path = candidate
+				break
+			}
+		}
+	}
+
+	claimed[path] = true
+	d.path = path
+	d.claimed = true
+	return nil
+}
+
+// existingFile returns the file --no-clobber should keep instead of
+// downloading, if any.
+func (d *download) existingFile() string {
+	for _, path := range []string{d.path, d.path + ".html"} {
+		if fileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// rotateBackups keeps up to --backups previous versions of path as path.1
+// (the most recent) to path.N before it is replaced.
+func rotateBackups(path string) error {
+	backups := *flag.GetFlagValue(flag.BACKUPS_FLAG).(*int)
+	if backups <= 0 || !fileExists(path) {
+		return nil
+	}
+
+	for i := backups - 1; i >= 1; i-- {
+		older := fmt.Sprintf("%s.%d", path, i)
+		if fileExists(older) {
+			if err := os.Rename(older, fmt.Sprintf("%s.%d", path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(path, path+".1")
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,157 @@
+package net
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"wget/flag"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncodings is the Accept-Encoding sent with GET requests for each
+// --compression mode.
+var acceptEncodings = map[string]string{
+	"auto": "gzip, deflate, br, zstd",
+	"gzip": "gzip",
+	"none": "identity",
+}
+
+// setAcceptEncoding asks for the encodings of the --compression mode. Range
+// requests ask for the identity encoding instead, since their offsets must
+// match the decoded file on disk.
+func setAcceptEncoding(req *http.Request) {
+	if req.Method != "GET" {
+		return
+	}
+	req.Header.Set("Accept-Encoding", acceptEncodings[*flag.GetFlagValue(flag.COMPRESSION_FLAG).(*string)])
+}
+
+// decodes reports whether the body of resp is decoded before it is written.
+// With --compression=none, or an encoding we don't know, the raw stream is
+// kept.
+func decodes(resp *http.Response) bool {
+	encoding := resp.Header.Get("Content-Encoding")
+	if encoding == "" || *flag.GetFlagValue(flag.COMPRESSION_FLAG).(*string) == "none" {
+		return false
+	}
+	for _, e := range contentCodings(encoding) {
+		if !supportedCoding(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func contentCodings(encoding string) []string {
+	var codings []string
+	for _, e := range strings.Split(encoding, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e != "" && e != "identity" {
+			codings = append(codings, e)
+		}
+	}
+	return codings
+}
+
+func supportedCoding(e string) bool {
+	switch e {
+	case "gzip", "x-gzip", "deflate", "br", "zstd":
+		return true
+	}
+	return false
+}
+
+type decoder struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decoder) Close() error {
+	for _, c := range d.closers {
+		c.Close()
+	}
+	return nil
+}
+
+// NewDecoder decodes r, sent with the given Content-Encoding. Codings are
+// listed in the order they were applied, so they are undone in reverse.
+func NewDecoder(r io.Reader, encoding string) (io.ReadCloser, error) {
+	d := &decoder{Reader: r}
+	codings := contentCodings(encoding)
+	for i := len(codings) - 1; i >= 0; i-- {
+		var rc io.ReadCloser
+		var err error
+		switch codings[i] {
+		case "gzip", "x-gzip":
+			rc, err = gzip.NewReader(d.Reader)
+		case "deflate":
+			rc, err = newDeflateReader(d.Reader)
+		case "br":
+			rc = io.NopCloser(brotli.NewReader(d.Reader))
+		case "zstd":
+			var z *zstd.Decoder
+			z, err = zstd.NewReader(d.Reader, zstd.WithDecoderConcurrency(1))
+			if err == nil {
+				rc = z.IOReadCloser()
+			}
+		default:
+			err = fmt.Errorf("unsupported Content-Encoding %q", codings[i])
+		}
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		d.Reader = rc
+		d.closers = append(d.closers, rc)
+	}
+	return d, nil
+}
+
+// readContent reads a whole body in memory, decoded, for pages whose links
+// are extracted without saving them.
+func readContent(resp *http.Response, body io.Reader) ([]byte, error) {
+	r := body
+	if decodes(resp) {
+		decoder, err := NewDecoder(body, resp.Header.Get("Content-Encoding"))
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		r = decoder
+	}
+	return io.ReadAll(r)
+}
+
+// newDeflateReader reads "deflate" bodies, which should be zlib streams but
+// are sent as raw deflate by some servers.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// countingReader counts the bytes read through it, which are the compressed
+// bytes when the body is decoded afterwards.
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,226 @@
+package net
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// cookieJar is a cookiejar.Jar that also remembers every cookie it accepted,
+// since the standard jar has no way to list its contents for --save-cookies.
+type cookieJar struct {
+	*cookiejar.Jar
+	mu      sync.Mutex
+	cookies map[string]savedCookie
+}
+
+type savedCookie struct {
+	domain   string
+	hostOnly bool
+	path     string
+	secure   bool
+	httpOnly bool
+	expires  time.Time
+	name     string
+	value    string
+}
+
+func (c savedCookie) key() string {
+	return c.domain + ";" + c.path + ";" + c.name
+}
+
+func (c savedCookie) session() bool {
+	return c.expires.IsZero()
+}
+
+func newCookieJar() *cookieJar {
+	// The error is always nil: cookiejar.New only fails on invalid options.
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &cookieJar{
+		Jar:     jar,
+		cookies: map[string]savedCookie{},
+	}
+}
+
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		j.record(u, c)
+	}
+}
+
+// record mirrors the bookkeeping of cookiejar.Jar.SetCookies: it resolves the
+// cookie's domain and path against u, drops cookies the jar would refuse, and
+// deletes cookies that are being expired.
+func (j *cookieJar) record(u *url.URL, c *http.Cookie) {
+	host := strings.ToLower(u.Hostname())
+	saved := savedCookie{
+		domain:   host,
+		hostOnly: true,
+		path:     c.Path,
+		secure:   c.Secure,
+		httpOnly: c.HttpOnly,
+		name:     c.Name,
+		value:    c.Value,
+	}
+
+	if c.Domain != "" {
+		domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return
+		}
+		if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain && host != domain {
+			return
+		}
+		saved.domain = domain
+		saved.hostOnly = false
+	}
+
+	if !strings.HasPrefix(saved.path, "/") {
+		saved.path = "/"
+		if i := strings.LastIndex(u.Path, "/"); i > 0 {
+			saved.path = u.Path[:i]
+		}
+	}
+
+	now := time.Now()
+	switch {
+	case c.MaxAge < 0:
+		delete(j.cookies, saved.key())
+		return
+	case c.MaxAge > 0:
+		saved.expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		if !c.Expires.After(now) {
+			delete(j.cookies, saved.key())
+			return
+		}
+		saved.expires = c.Expires
+	}
+
+	j.cookies[saved.key()] = saved
+}
+
+// LoadCookies adds the cookies of a Netscape cookies.txt file, as exported by
+// browsers, to the shared jar.
+func LoadCookies(path string) error {
+	jar := Client().Jar.(*cookieJar)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot load cookies: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("cannot load cookies: %s:%d: malformed line", path, lineNumber)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot load cookies: %s:%d: invalid expiry %q", path, lineNumber, fields[4])
+		}
+
+		domain := fields[0]
+		cookie := &http.Cookie{
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+			if cookie.Expires.Before(time.Now()) {
+				continue
+			}
+		}
+
+		scheme := "http"
+		if cookie.Secure {
+			scheme = "https"
+		}
+		u := &url.URL{Scheme: scheme, Host: strings.TrimPrefix(domain, "."), Path: cookie.Path}
+		jar.SetCookies(u, []*http.Cookie{cookie})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("cannot load cookies: %v", err)
+	}
+	return nil
+}
+
+// SaveCookies writes the jar to path in the Netscape cookies.txt format.
+// Session cookies are only kept when keepSession is set.
+func SaveCookies(path string, keepSession bool) error {
+	jar := Client().Jar.(*cookieJar)
+
+	jar.mu.Lock()
+	cookies := make([]savedCookie, 0, len(jar.cookies))
+	for _, c := range jar.cookies {
+		if c.session() && !keepSession {
+			continue
+		}
+		if !c.session() && c.expires.Before(time.Now()) {
+			continue
+		}
+		cookies = append(cookies, c)
+	}
+	jar.mu.Unlock()
+
+	sort.Slice(cookies, func(i, j int) bool {
+		return cookies[i].key() < cookies[j].key()
+	})
+
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	b.WriteString("# Generated by wget. Edit at your own risk.\n\n")
+	for _, c := range cookies {
+		domain, includeSubdomains := c.domain, "FALSE"
+		if !c.hostOnly {
+			domain, includeSubdomains = "."+c.domain, "TRUE"
+		}
+		if c.httpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expires int64
+		if !c.session() {
+			expires = c.expires.Unix()
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, includeSubdomains, c.path, strings.ToUpper(strconv.FormatBool(c.secure)), expires, c.name, c.value)
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("cannot save cookies: %v", err)
+	}
+	return nil
+}
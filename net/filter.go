@@ -0,0 +1,63 @@
+package net
+
+import (
+	"mime"
+	"path"
+	"slices"
+	"strings"
+	"wget/flag"
+)
+
+// fileRejected applies the -A, -R, --accept-regex and --reject-regex rules to
+// a file about to be saved from u. Reject rules win: a file matching one is
+// rejected even if it is accepted otherwise. When accept rules are given,
+// the file must match each of them.
+func fileRejected(u string, f FileInfos) bool {
+	if URLRejected(u) || matchesFileList(f, *flag.GetFlagValue(flag.REJECT_FLAG).(*[]string)) {
+		return true
+	}
+	accept := *flag.GetFlagValue(flag.ACCEPT_FLAG).(*[]string)
+	return len(accept) > 0 && !matchesFileList(f, accept)
+}
+
+// crawlsRejected reports whether a rejected file is still read for its
+// links, which a mirror does for HTML unless --no-follow-rejected is given.
+func crawlsRejected(f FileInfos) bool {
+	return flag.Recursive() && strings.Contains(f.ContentType, "text/html") && !flag.Provided(flag.NO_FOLLOW_REJECTED_FLAG)
+}
+
+// URLRejected reports whether --accept-regex or --reject-regex rejects u. A
+// mirror doesn't request such URLs at all, unlike files rejected by -A or -R
+// whose type is only known from the response.
+func URLRejected(u string) bool {
+	if re := flag.GetRejectRegex(); re != nil && re.MatchString(u) {
+		return true
+	}
+	re := flag.GetAcceptRegex()
+	return re != nil && !re.MatchString(u)
+}
+
+// matchesFileList reports whether f matches an entry of an -A or -R list: a
+// file name suffix (jpg or logo.png), a file name pattern (*.jpg), or a MIME
+// type or pattern (image/*). Suffixes also match the end of the MIME type,
+// so that html matches text/html.
+func matchesFileList(f FileInfos, list []string) bool {
+	fileName := strings.ToLower(f.FileName)
+	mediaType, _, err := mime.ParseMediaType(f.ContentType)
+	if err != nil {
+		mediaType = f.ContentType
+	}
+
+	return slices.ContainsFunc(list, func(e string) bool {
+		if strings.Contains(e, "/") {
+			matched, _ := path.Match(strings.ToLower(e), mediaType)
+			return matched
+		}
+		if strings.ContainsAny(e, "*?[") {
+			matched, _ := path.Match(strings.ToLower(e), fileName)
+			return matched
+		}
+		e = strings.ToLower(e)
+		return strings.HasSuffix(fileName, e) || (mediaType != "" && strings.HasSuffix(mediaType, e))
+	})
+}
@@ -0,0 +1,51 @@
+package net
+
+import (
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"wget/flag"
+	"wget/state"
+	"wget/utils"
+)
+
+// HostAllowed reports whether the mirror crawls the host of u. Without
+// --span-hosts or --domains only the host of the first page is. --domains
+// adds the listed domains and their subdomains, --span-hosts any host, and
+// page requisites may come from any host. --exclude-domains always wins.
+func HostAllowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	matches := func(domain string) bool {
+		return utils.MatchesDomain(host, domain)
+	}
+
+	if slices.ContainsFunc(flag.GetExcludedDomains(), matches) {
+		return false
+	}
+	if strings.EqualFold(host, state.GetBaseUrl().Hostname()) || state.IsRequisite(u.String()) {
+		return true
+	}
+	if domains := flag.GetDomains(); len(domains) > 0 {
+		return slices.ContainsFunc(domains, matches)
+	}
+	return flag.Provided(flag.SPAN_HOSTS_FLAG)
+}
+
+// hostDir is the directory a mirror saves the files of u's host into.
+func hostDir(u *url.URL) string {
+	return filepath.Join(*flag.GetFlagValue(flag.PATH_FLAG).(*string), u.Host)
+}
+
+// MirrorPath is where a mirror saves the page at u, under the directory of
+// its host. Directory URLs are saved as their index.html.
+func MirrorPath(u *url.URL) string {
+	urlPath := u.Path
+	if urlPath == "" || strings.HasSuffix(urlPath, "/") {
+		urlPath += "index.html"
+	}
+	return filepath.Join(hostDir(u), urlPath)
+}
@@ -1,6 +1,8 @@
 package net
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -9,7 +11,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"slices"
+	"strconv"
 	"strings"
 	"time"
 	"wget/flag"
@@ -20,6 +22,8 @@ import (
 	"github.com/vbauerster/mpb/decor"
 )
 
+const defaultUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+
 type rateLimitedReader struct {
 	reader     io.Reader
 	rateLimit  int64
@@ -80,188 +84,576 @@ func NewRateLimitedReader(r io.Reader, limit int64) io.Reader {
 	}
 }
 
-func extIgnored(f FileInfos) bool {
-	fileExt := strings.TrimPrefix(filepath.Ext(f.FileName), ".")
-	ext := *flag.GetFlagValue(flag.REJECT_FLAG).(*[]string)
+type download struct {
+	url        string
+	parsedURL  *url.URL
+	infos      FileInfos
+	filename   string
+	path       string
+	outputPath string
+	speedLimit int64
+	claimed    bool
+	// partial is set once this run writes the .part file, which a retry
+	// may then resume.
+	partial  bool
+	digests  *digests
+	encoding string
+	decoded  bool
+	// content is a rejected page, read only for its links.
+	content []byte
+	// unchanged is set when the local copy is kept instead of downloading.
+	unchanged bool
+}
 
-	extRejected := slices.ContainsFunc(ext, func(e string) bool {
-		return strings.HasSuffix(f.ContentType, e) || e == fileExt
-	})
+func newDownload(u string, speedLimit int64) *download {
+	parsedURL, _ := url.Parse(u)
 
-	return extRejected
+	d := &download{
+		url:        u,
+		parsedURL:  parsedURL,
+		outputPath: *flag.GetFlagValue(flag.PATH_FLAG).(*string),
+		speedLimit: speedLimit,
+	}
+	if flag.IsMirror() {
+		d.outputPath = hostDir(parsedURL)
+	}
+	d.setInfos(FileInfos{FileName: filepath.Base(parsedURL.Path)})
+	return d
 }
 
-func GetWithSpeedLimit(p *mpb.Progress, u string, speedLimit int64) {
-	client := &http.Client{}
-	fileInfos := GetFileInfos(u)
-	contentLength := fileInfos.ContentLenght
-	userAgent := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
-	req, _ := http.NewRequest("GET", u, nil)
-	req.Header.Add("User-Agent", userAgent)
-	resp, err := client.Do(req)
-	if contentLength == 0 {
-		contentLength = resp.ContentLength
+// setInfos records what is known about the remote file and derives the
+// local filename and path from it.
+func (d *download) setInfos(fileInfos FileInfos) {
+	var filename string
+	if flag.Provided(flag.OUTPUT_FLAG) {
+		filename = *flag.GetFlagValue(flag.OUTPUT_FLAG).(*string)
+	} else {
+		filename = fileInfos.FileName
 	}
-	if err != nil {
-		errMsg := fmt.Errorf("couldn't get %s. reason: %v", u, err)
-		fmt.Printf("%v\n\n", errMsg)
-		if flag.IsMirror() {
-			state.Abort(u)
+
+	output_path := d.outputPath
+	var path string
+
+	if flag.IsMirror() {
+		// Mirrors keep the layout of the site under the host directory,
+		// like the link converter expects.
+		path = MirrorPath(d.parsedURL)
+		if d.parsedURL.Path == "" || strings.HasSuffix(d.parsedURL.Path, "/") {
+			filename = "index.html"
 		}
-		return
+	} else if flag.Provided(flag.OUTPUT_FLAG) {
+		path = filepath.Join(output_path, filename)
+	} else {
+		// Single downloads are saved under their basename in the -P
+		// directory. Base also keeps a Content-Disposition name from
+		// escaping it.
+		filename = filepath.Base(filename)
+		if filename == "." || filename == "/" || filename == "" {
+			filename = "index.html"
+		}
+		path = filepath.Join(output_path, filename)
 	}
-	defer resp.Body.Close()
 
-	var added bool
+	if strings.Contains(fileInfos.ContentType, "text/html") && filepath.Ext(path) != ".html" && !flag.Provided(flag.OUTPUT_FLAG) {
+		path += ".html"
+	}
 
-	if extIgnored(fileInfos) {
-		if flag.IsMirror() {
-			state.Abort(u)
+	d.infos = fileInfos
+	d.filename = filename
+	// A retry keeps the path the first attempt claimed.
+	if !d.claimed {
+		d.path = path
+	}
+}
+
+// needsHead reports whether the file must be probed with a HEAD request
+// before the download starts. Segmented downloads need to know the length
+// and range support up front, and resuming a partial file from an earlier
+// run needs the validators for If-Range. Everything else learns what it
+// needs from the GET response.
+func (d *download) needsHead(resume bool) bool {
+	if *flag.GetFlagValue(flag.SEGMENTS_FLAG).(*int) > 1 {
+		return true
+	}
+	if !resume {
+		return false
+	}
+	for _, path := range []string{d.path, d.path + ".html", d.partPath(), d.path + ".html.part"} {
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			return true
 		}
-		return
 	}
+	return false
+}
 
-	if resp.StatusCode != 200 {
-		errMsg := fmt.Errorf("couldn't get %s. reason: %v", u, resp.Status)
-		fmt.Printf("%v\n\n", errMsg)
-		if flag.IsMirror() {
-			state.Abort(u)
+func GetWithSpeedLimit(p *mpb.Progress, u string, speedLimit int64) {
+	d := newDownload(u, speedLimit)
+
+	tries := *flag.GetFlagValue(flag.TRIES_FLAG).(*int)
+	waitRetry := time.Duration(*flag.GetFlagValue(flag.WAITRETRY_FLAG).(*int)) * time.Second
+	resume := flag.Provided(flag.CONTINUE_FLAG)
+
+	if flag.Provided(flag.NO_CLOBBER_FLAG) {
+		if existing := d.existingFile(); existing != "" {
+			fmt.Printf("File %s already there; not retrieving.\n\n", existing)
+			d.path = existing
+			d.unchanged = true
+			d.processFile()
+			return
 		}
+	}
+
+	if local := d.unchangedPage(); local != "" {
+		fmt.Printf("Sitemap lastmod not newer than local file %s -- not retrieving.\n\n", local)
+		d.path = local
+		d.unchanged = true
+		d.processFile()
 		return
 	}
 
-	if state.IsBackground() {
-		fmt.Printf("Getting %s\n", u)
-		fmt.Printf("sending request, awaiting response... status %s\n", resp.Status)
-		fmt.Printf("content size: %s\n", utils.ConvertedLenghtStr(contentLength))
+	// A failed HEAD is reported and leaves the names derived from the URL,
+	// and the GET learns the rest.
+	if d.needsHead(resume) {
+		fileInfos, err := GetFileInfos(u)
+		if err != nil {
+			fmt.Printf("couldn't get file infos of %s. reason: %v\n", u, err)
+		} else {
+			d.setInfos(fileInfos)
+			if fileRejected(u, d.infos) && !crawlsRejected(d.infos) {
+				if flag.IsMirror() {
+					state.Abort(u)
+				}
+				return
+			}
+		}
 	}
 
-	var filename string
-	if flag.Provided(flag.OUTPUT_FLAG) {
-		filename = *flag.GetFlagValue(flag.OUTPUT_FLAG).(*string)
-	} else {
-		filename = fileInfos.FileName
+	for attempt := 1; ; attempt++ {
+		var err error
+		segmented := d.canSegment(resume)
+		if segmented {
+			err = d.getSegmented(p, *flag.GetFlagValue(flag.SEGMENTS_FLAG).(*int))
+		} else {
+			err = d.get(p, resume)
+		}
+
+		if err == nil {
+			d.processFile()
+			return
+		}
+
+		if errors.Is(err, errAlreadyMirrored) || errors.Is(err, errSamePath) {
+			fmt.Printf("%s %v\n\n", u, err)
+		}
+		if errors.Is(err, errRejected) || errors.Is(err, errAlreadyMirrored) || errors.Is(err, errSamePath) {
+			if flag.IsMirror() {
+				state.Abort(u)
+			}
+			return
+		}
+
+		var retryErr *retryableError
+		if !errors.As(err, &retryErr) || (tries > 0 && attempt >= tries) {
+			errMsg := fmt.Errorf("couldn't get %s. reason: %v", u, err)
+			if certErr := certificateError(d.parsedURL.Host, err); certErr != "" {
+				errMsg = fmt.Errorf("couldn't get %s. %s", u, certErr)
+			}
+			fmt.Printf("%v\n\n", errMsg)
+			if errors.Is(err, errChecksumMismatch) {
+				state.Fail()
+			}
+			if flag.IsMirror() {
+				state.Abort(u)
+			}
+			return
+		}
+
+		delay := retryDelay(attempt, waitRetry, retryErr.retryAfter)
+		fmt.Printf("couldn't get %s. reason: %v. retrying in %v (attempt %d)\n\n", u, err, delay.Round(time.Millisecond), attempt+1)
+		time.Sleep(delay)
+
+		// A single stream picks up from the .part file the failed attempt
+		// wrote, with If-Range from its response. Any other attempt,
+		// segmented or one that wrote nothing, starts over.
+		if !segmented && d.partial {
+			resume = true
+		}
 	}
+}
 
-	output_path := flag.GetFlagValue(flag.PATH_FLAG).(*string)
-	var path string
+func (d *download) processFile() {
+	if flag.IsMirror() && d.content != nil {
+		state.AddToReadyExtract(state.FileToProcess{Url: d.parsedURL, Content: d.content})
+		return
+	}
+	if flag.IsMirror() {
+		f := state.FileToProcess{
+			Path: d.path,
+			Url:  d.parsedURL,
+		}
+		if !d.decoded {
+			f.Encoding = d.encoding
+		}
+		state.AddToReadyExtract(f)
+	}
 
-	parsedURL, _ := url.Parse(u)
-	// if flag.IsMirror() {
-	filePath := filepath.Join(*output_path, filepath.Base(parsedURL.Path))
-	if filePath == *output_path {
-		filename = "index.html"
-		filePath = filepath.Join(*output_path, filename)
-	} else {
-		filePath = filepath.Join(*output_path, parsedURL.Path)
+	if state.IsBackground() && !d.unchanged {
+		fmt.Printf("saving file to: %s\n", d.path)
+		fmt.Printf("Downloaded %s\n\n", d.url)
 	}
-	path = filePath
+}
 
-	if strings.Contains(fileInfos.ContentType, "text/html") && filepath.Ext(path) != ".html" {
-		path += ".html"
+// get makes a single attempt at downloading the file. When resume is set, it
+// asks only for the bytes that are missing from the file on disk.
+func (d *download) get(p *mpb.Progress, resume bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := NewRequest(ctx, "GET", d.url)
+	if err != nil {
+		return err
 	}
 
-	out_file, err := os.Create(path)
+	var offset int64
+	if resume {
+		d.adoptPartial()
+		offset = setRangeHeaders(req, d.partPath(), d.infos)
+	}
+
+	var local string
+	if offset == 0 && flag.Provided(flag.TIMESTAMPING_FLAG) {
+		local = setConditionalHeaders(req, d)
+	}
+
+	resp, err := Client().Do(req)
 	if err != nil {
-		return
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+	logRedirects(resp)
+
+	_, readTimeout := getTimeouts()
+	body := newIdleTimeoutReader(resp.Body, readTimeout, cancel)
+	defer body.Stop()
+
+	contentLength := d.infos.ContentLenght
+
+	if local != "" && resp.StatusCode == http.StatusNotModified {
+		fmt.Printf("Server file no newer than local file %s -- not retrieving.\n\n", local)
+		d.path = local
+		d.unchanged = true
+		return nil
 	}
-	defer out_file.Close()
 
-	processFile := func() {
-		if flag.IsMirror() && !added {
-			f := state.FileToProcess{
-				Path: path,
-				Url:  parsedURL,
+	// The .part file is complete, and is checked and moved into place like
+	// any finished download.
+	if offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		if err := d.claimPath(true); err != nil {
+			return err
+		}
+		out_file, err := os.OpenFile(d.partPath(), os.O_RDWR, 0644)
+		if err != nil {
+			return err
+		}
+		defer out_file.Close()
+		d.digests = d.newDigests(nil)
+		if err := d.commit(out_file); err != nil {
+			return err
+		}
+		fmt.Printf("%s is already fully retrieved; nothing to do.\n\n", d.path)
+		return nil
+	}
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		if total > 0 {
+			contentLength = total
+		} else if resp.ContentLength >= 0 {
+			contentLength = offset + resp.ContentLength
+		}
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the range, or the file changed since the
+		// partial download: start over from the beginning.
+		offset = 0
+		if flag.IsMirror() {
+			if err := d.followRedirect(resp); err != nil {
+				return err
 			}
-			state.AddToReadyExtract(f)
-			added = true
 		}
+		d.setInfos(fileInfosFromResponse(resp))
+		if fileRejected(d.url, d.infos) {
+			if !crawlsRejected(d.infos) {
+				return errRejected
+			}
+			// A rejected page isn't saved, but its links are followed.
+			d.content, err = readContent(resp, body)
+			return transportError(err)
+		}
+		if flag.IsMirror() {
+			if err := d.mkdirMirror(); err != nil {
+				return err
+			}
+		}
+		if local != "" && notNewer(resp, local) {
+			fmt.Printf("Server file no newer than local file %s -- not retrieving.\n\n", local)
+			d.path = local
+			d.unchanged = true
+			return nil
+		}
+		contentLength = resp.ContentLength
+	default:
+		return statusError(resp)
+	}
 
-		if state.IsBackground() {
-			fmt.Printf("saving file to: %s\n", path)
-			fmt.Printf("Downloaded %s\n\n", u)
+	if err := d.claimPath(offset > 0); err != nil {
+		return err
+	}
+	d.printFinalURL()
+	// A range is always asked for in the identity encoding, so only a
+	// complete body is decoded.
+	d.encoding = resp.Header.Get("Content-Encoding")
+	d.decoded = offset == 0 && decodes(resp)
+	d.digests = d.newDigests(resp)
+
+	if state.IsBackground() {
+		fmt.Printf("Getting %s\n", d.url)
+		fmt.Printf("sending request, awaiting response... status %s\n", resp.Status)
+		fmt.Printf("content size: %s\n", utils.ConvertedLenghtStr(contentLength))
+		if offset > 0 {
+			fmt.Printf("resuming from: %s\n", utils.ConvertedLenghtStr(offset))
 		}
 	}
 
+	out_file, err := openOutputFile(d.partPath(), offset)
+	if err != nil {
+		return err
+	}
+	defer out_file.Close()
+	d.partial = true
+
+	if err := d.digests.seed(out_file, offset); err != nil {
+		return err
+	}
+
 	if state.IsBackground() {
-		limitedReader := NewRateLimitedReader(resp.Body, speedLimit)
-		_, err = io.Copy(out_file, limitedReader)
-		if err != nil {
-			fmt.Println(err)
-			return
+		limitedReader := NewRateLimitedReader(body, d.speedLimit)
+		if err := d.copyBody(out_file, limitedReader); err != nil {
+			return transportError(err)
 		}
+		return d.commit(out_file)
+	}
 
-		processFile()
+	bar := newBar(p, contentLength, d.filename, d.outputPath, resp.Status)
 
-	} else {
-		convertedLenght := utils.ConvertedLenghtStr(contentLength)
-
-		bar := p.AddBar(contentLength,
-			mpb.BarWidth(int(float32(utils.GetTerminalWidth())*0.45)),
-			mpb.OptionOnCondition(
-				mpb.AppendDecorators(
-					decor.AverageSpeed(decor.UnitKB, "% .1f"),
-					decor.Percentage(decor.WCSyncSpace),
-					decor.OnComplete(
-						decor.AverageETA(decor.ET_STYLE_GO, decor.WCSyncSpace),
-						"✅",
-					),
-				),
-
-				func() bool {
-					return resp.StatusCode == 200
-				},
-			),
-			mpb.OptionOnCondition(
-				mpb.BarStyle(" ▓▓░ "),
-				func() bool {
-					return resp.StatusCode == 200
-				},
-			),
-			mpb.BarNewLineExtend(func(w io.Writer, s *decor.Statistics) {
-				if !s.Completed {
-					w.Write([]byte(fmt.Sprintf("Downloading: %s | %v / %v | %v", filename, utils.ConvertedLenghtStr(s.Current), convertedLenght, resp.Status)))
-				} else {
-					w.Write([]byte(fmt.Sprintf("%s saved into %s", filename, *output_path)))
-				}
-				w.Write([]byte("\n\n"))
-			}),
-		)
+	if offset > 0 {
+		bar.IncrBy(int(offset))
+	}
+
+	// The bar and the rate limit count the bytes received, which are the
+	// compressed ones when the body is decoded.
+	reader := bar.ProxyReader(body)
+	limitedReader := &countingReader{Reader: NewRateLimitedReader(reader, d.speedLimit)}
 
-		reader := bar.ProxyReader(resp.Body)
-		limitedReader := NewRateLimitedReader(reader, speedLimit)
+	if err := d.copyBody(out_file, limitedReader); err != nil {
+		p.Abort(bar, true)
+		return transportError(err)
+	}
 
-		n, err := io.Copy(out_file, limitedReader)
+	if contentLength < 0 {
+		bar.SetTotal(offset+limitedReader.n, true)
+	}
+
+	if err := d.commit(out_file); err != nil {
+		p.Abort(bar, true)
+		return err
+	}
+	return nil
+}
+
+// copyBody writes the body to out_file, decoding it first unless the raw
+// stream is kept.
+func (d *download) copyBody(out_file *os.File, body io.Reader) error {
+	if d.decoded {
+		decoder, err := NewDecoder(body, d.encoding)
 		if err != nil {
-			bar.SetTotal(n, true)
-			return
+			return err
 		}
+		defer decoder.Close()
+		body = decoder
+	}
+	_, err := io.Copy(d.digests.writer(out_file), body)
+	return err
+}
+
+// partPath is where the file is written until it is complete. A failed
+// download leaves it there for --continue to pick up.
+func (d *download) partPath() string {
+	return d.path + ".part"
+}
+
+// adoptPartial lets --continue resume a partial file left at the final path
+// by another tool by moving it to the .part name first. A retry never does,
+// as the file there may be a complete one from an earlier run.
+func (d *download) adoptPartial() {
+	if !flag.Provided(flag.CONTINUE_FLAG) {
+		return
+	}
+	if _, err := os.Stat(d.partPath()); err == nil {
+		return
+	}
+	if info, err := os.Stat(d.path); err == nil && info.Mode().IsRegular() && info.Size() > 0 {
+		os.Rename(d.path, d.partPath())
+	}
+}
 
-		if contentLength < 0 {
-			bar.SetTotal(n, true)
+// commit moves a completed .part file into place, so that nothing appears at
+// the final path unless the whole file was received. A file that fails its
+// checksum is removed instead.
+func (d *download) commit(out_file *os.File) error {
+	if err := d.digests.verify(out_file); err != nil {
+		out_file.Close()
+		os.Remove(out_file.Name())
+		return err
+	}
+	if flag.Provided(flag.FSYNC_FLAG) {
+		if err := out_file.Sync(); err != nil {
+			return err
 		}
+	}
+	if err := out_file.Close(); err != nil {
+		return err
+	}
+	if err := rotateBackups(d.path); err != nil {
+		return err
+	}
+	if err := os.Rename(out_file.Name(), d.path); err != nil {
+		return err
+	}
+	d.applyServerTimestamp()
+	d.digests.printDigest(d.path)
+	if flag.IsMirror() {
+		if info, err := os.Stat(d.path); err == nil {
+			state.AddBytes(info.Size())
+		}
+	}
+	return nil
+}
+
+func newBar(p *mpb.Progress, contentLength int64, filename string, outputPath string, status string) *mpb.Bar {
+	convertedLenght := utils.ConvertedLenghtStr(contentLength)
+
+	return p.AddBar(contentLength,
+		mpb.BarWidth(int(float32(utils.GetTerminalWidth())*0.45)),
+		mpb.AppendDecorators(
+			decor.AverageSpeed(decor.UnitKB, "% .1f"),
+			decor.Percentage(decor.WCSyncSpace),
+			decor.OnComplete(
+				decor.AverageETA(decor.ET_STYLE_GO, decor.WCSyncSpace),
+				"✅",
+			),
+		),
+		mpb.BarStyle(" ▓▓░ "),
+		mpb.BarNewLineExtend(func(w io.Writer, s *decor.Statistics) {
+			if !s.Completed {
+				w.Write([]byte(fmt.Sprintf("Downloading: %s | %v / %v | %v", filename, utils.ConvertedLenghtStr(s.Current), convertedLenght, status)))
+			} else {
+				w.Write([]byte(fmt.Sprintf("%s saved into %s", filename, outputPath)))
+			}
+			w.Write([]byte("\n\n"))
+		}),
+	)
+}
 
-		processFile()
+// setRangeHeaders asks the server for the part of u that is missing from the
+// file at path and returns the number of bytes already on disk.
+func setRangeHeaders(req *http.Request, path string, fileInfos FileInfos) int64 {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		return 0
 	}
+
+	offset := info.Size()
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	req.Header.Set("Accept-Encoding", "identity")
+
+	// If-Range only accepts a strong validator, so weak ETags fall back to
+	// the Last-Modified date.
+	if fileInfos.ETag != "" && !strings.HasPrefix(fileInfos.ETag, "W/") {
+		req.Header.Set("If-Range", fileInfos.ETag)
+	} else if fileInfos.LastModified != "" {
+		req.Header.Set("If-Range", fileInfos.LastModified)
+	}
+
+	return offset
+}
+
+// parseContentRange parses a "bytes start-end/total" header. total is -1 when
+// the server reports it as unknown.
+func parseContentRange(h string) (start int64, total int64, ok bool) {
+	var end int64
+	var totalStr string
+	if _, err := fmt.Sscanf(h, "bytes %d-%d/%s", &start, &end, &totalStr); err != nil {
+		return 0, 0, false
+	}
+	if totalStr == "*" {
+		return start, -1, true
+	}
+	total, err := strconv.ParseInt(totalStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+func openOutputFile(path string, offset int64) (*os.File, error) {
+	if offset > 0 {
+		return os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0644)
+	}
+	return os.Create(path)
 }
 
+// errRejected is returned when the response turns out to be a file the user
+// asked to skip with --reject.
+var errRejected = errors.New("rejected")
+
 type FileInfos struct {
 	ContentType   string
 	ContentLenght int64
 	FileName      string
+	ETag          string
+	LastModified  string
+	AcceptRanges  bool
+	// FinalURL is the URL the redirects, if any, ended at.
+	FinalURL string
 }
 
-func GetFileInfos(url string) FileInfos {
-	client := &http.Client{}
-	userAgent := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
-	req, _ := http.NewRequest("HEAD", url, nil)
-	req.Header.Add("User-Agent", userAgent)
-	resp, err := client.Do(req)
+// GetFileInfos probes url with a HEAD request. A failed request or a non-2xx
+// status is an error, as its headers don't describe the file.
+func GetFileInfos(url string) (FileInfos, error) {
+	req, err := NewRequest(context.Background(), "HEAD", url)
 	if err != nil {
-		return FileInfos{}
+		return FileInfos{}, err
+	}
+	resp, err := Client().Do(req)
+	if err != nil {
+		return FileInfos{}, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return FileInfos{}, statusError(resp)
+	}
 
+	return fileInfosFromResponse(resp), nil
+}
+
+// fileInfosFromResponse reads the file's metadata from the headers of a HEAD
+// or GET response. The filename comes from Content-Disposition, or else from
+// the URL given by namingURL.
+func fileInfosFromResponse(resp *http.Response) FileInfos {
 	contentLength := resp.ContentLength
 	contentType := resp.Header.Get("Content-Type")
 
@@ -272,8 +664,9 @@ func GetFileInfos(url string) FileInfos {
 	var filename string
 	var defaultFilename string
 
-	splitted_url := strings.Split(resp.Request.URL.Path, "/")
-	if strings.HasSuffix(resp.Request.URL.Path, "/") {
+	namingPath := namingURL(resp).Path
+	splitted_url := strings.Split(namingPath, "/")
+	if strings.HasSuffix(namingPath, "/") {
 		defaultFilename = splitted_url[len(splitted_url)-2]
 
 	} else {
@@ -299,5 +692,16 @@ func GetFileInfos(url string) FileInfos {
 		ContentType:   contentType,
 		ContentLenght: contentLength,
 		FileName:      filename,
+		ETag:          resp.Header.Get("ETag"),
+		LastModified:  resp.Header.Get("Last-Modified"),
+		AcceptRanges:  resp.Header.Get("Accept-Ranges") == "bytes",
+		FinalURL:      resp.Request.URL.String(),
+	}
+}
+
+// printFinalURL prints the URL the download was redirected to, if it was.
+func (d *download) printFinalURL() {
+	if d.infos.FinalURL != "" && d.infos.FinalURL != d.url {
+		fmt.Printf("final URL: %s\n", d.infos.FinalURL)
 	}
 }
@@ -0,0 +1,116 @@
+package net
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+var (
+	netrcEntries []netrcEntry
+	netrcOnce    sync.Once
+)
+
+// netrcLookup returns the login and password ~/.netrc (or $NETRC) holds for
+// host, falling back to its "default" entry when defaultOk is set.
+func netrcLookup(host string, defaultOk bool) (string, string, bool) {
+	netrcOnce.Do(func() {
+		netrcEntries = readNetrc(netrcPath())
+	})
+
+	for _, e := range netrcEntries {
+		if e.machine == host {
+			return e.login, e.password, true
+		}
+	}
+	if defaultOk {
+		for _, e := range netrcEntries {
+			if e.machine == "" {
+				return e.login, e.password, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// readNetrc parses the machine, default, login and password tokens of a
+// netrc file. macdef bodies are skipped up to the next blank line. A missing
+// or unreadable file yields no entries.
+func readNetrc(path string) []netrcEntry {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var entries []netrcEntry
+	var current *netrcEntry
+	inMacro := false
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine":
+				if i+1 < len(fields) {
+					i++
+					entries = append(entries, netrcEntry{machine: fields[i]})
+					current = &entries[len(entries)-1]
+				}
+			case "default":
+				entries = append(entries, netrcEntry{})
+				current = &entries[len(entries)-1]
+			case "login":
+				if i+1 < len(fields) && current != nil {
+					i++
+					current.login = fields[i]
+				}
+			case "password":
+				if i+1 < len(fields) && current != nil {
+					i++
+					current.password = fields[i]
+				}
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,78 @@
+package net
+
+import (
+	stdnet "net"
+	"net/http"
+	"net/url"
+	"os"
+	"wget/flag"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// configureProxy routes the transport through the proxy chosen by --proxy or
+// by the http_proxy, https_proxy, all_proxy and no_proxy environment
+// variables. HTTP(S) proxies go through Transport.Proxy; a SOCKS5 proxy
+// replaces the dialer instead, still bypassing the hosts listed in no_proxy.
+func configureProxy(transport *http.Transport, dialer *stdnet.Dialer) {
+	transport.Proxy = nil
+	if flag.Provided(flag.NO_PROXY_FLAG) {
+		return
+	}
+
+	cfg := httpproxy.FromEnvironment()
+	explicit := *flag.GetFlagValue(flag.PROXY_FLAG).(*string)
+	if explicit != "" {
+		cfg.HTTPProxy = explicit
+		cfg.HTTPSProxy = explicit
+	}
+
+	if socksURL := socksProxy(explicit); socksURL != nil {
+		// FromURL only fails on schemes other than socks5 and socks5h.
+		socks, _ := proxy.FromURL(socksURL, dialer)
+		perHost := proxy.NewPerHost(socks, dialer)
+		perHost.AddFromString(cfg.NoProxy)
+		transport.DialContext = perHost.DialContext
+		return
+	}
+
+	proxyFunc := cfg.ProxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, err := proxyFunc(req.URL)
+		if u == nil || err != nil {
+			return u, err
+		}
+		return withProxyCredentials(u), nil
+	}
+}
+
+// socksProxy returns the SOCKS5 proxy to dial through, if --proxy or, when
+// it is not given, all_proxy names one.
+func socksProxy(explicit string) *url.URL {
+	candidate := explicit
+	if candidate == "" {
+		candidate = os.Getenv("ALL_PROXY")
+	}
+	if candidate == "" {
+		candidate = os.Getenv("all_proxy")
+	}
+
+	u, err := url.Parse(candidate)
+	if err != nil || (u.Scheme != "socks5" && u.Scheme != "socks5h") {
+		return nil
+	}
+	return withProxyCredentials(u)
+}
+
+// withProxyCredentials adds --proxy-user and --proxy-password to a proxy URL
+// that doesn't carry its own. The transport turns them into a
+// Proxy-Authorization header, or SOCKS5 username/password authentication.
+func withProxyCredentials(u *url.URL) *url.URL {
+	if u.User != nil || !flag.Provided(flag.PROXY_USER_FLAG) {
+		return u
+	}
+	withUser := *u
+	withUser.User = url.UserPassword(*flag.GetFlagValue(flag.PROXY_USER_FLAG).(*string), *flag.GetFlagValue(flag.PROXY_PASSWORD_FLAG).(*string))
+	return &withUser
+}
@@ -0,0 +1,90 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"wget/flag"
+	"wget/state"
+)
+
+// errAlreadyMirrored is returned when a mirrored page redirects to a URL
+// that is already part of the mirror.
+var errAlreadyMirrored = errors.New("redirects to a page already mirrored")
+
+// checkRedirect enforces --max-redirect, --no-cross-host-redirects and
+// --no-https-downgrade on every hop of a redirect chain.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	maxRedirect := *flag.GetFlagValue(flag.MAX_REDIRECT_FLAG).(*int)
+	if len(via) > maxRedirect {
+		return fmt.Errorf("%d redirections exceeded", maxRedirect)
+	}
+
+	first := via[0].URL
+	if flag.Provided(flag.NO_CROSS_HOST_REDIRECTS_FLAG) && !strings.EqualFold(req.URL.Hostname(), first.Hostname()) {
+		return fmt.Errorf("refusing redirect from %s to another host: %s", first.Host, req.URL)
+	}
+
+	prev := via[len(via)-1].URL
+	if flag.Provided(flag.NO_HTTPS_DOWNGRADE_FLAG) && prev.Scheme == "https" && req.URL.Scheme == "http" {
+		return fmt.Errorf("refusing redirect from HTTPS to HTTP: %s", req.URL)
+	}
+	return nil
+}
+
+// redirectChain returns the requests that led to resp, from the original one
+// to the one resp answers.
+func redirectChain(resp *http.Response) []*http.Request {
+	var chain []*http.Request
+	for req := resp.Request; req != nil; {
+		chain = append([]*http.Request{req}, chain...)
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	return chain
+}
+
+// namingURL is the URL files are named after: the one asked for, or with
+// --trust-server-names the one the redirects ended at.
+func namingURL(resp *http.Response) *url.URL {
+	if flag.Provided(flag.TRUST_SERVER_NAMES_FLAG) {
+		return resp.Request.URL
+	}
+	return redirectChain(resp)[0].URL
+}
+
+func logRedirects(resp *http.Response) {
+	for _, req := range redirectChain(resp)[1:] {
+		fmt.Printf("%s redirected to %s (%s)\n", req.Response.Request.URL, req.URL, req.Response.Status)
+	}
+}
+
+// followRedirect saves a mirrored page that redirects to a host the mirror
+// crawls under the URL it ended at, and records the redirect so that links
+// to either URL are converted to the same file.
+func (d *download) followRedirect(resp *http.Response) error {
+	final := resp.Request.URL.String()
+	if final == d.url || !HostAllowed(resp.Request.URL) {
+		return nil
+	}
+
+	state.AddRedirect(d.url, final)
+	if !state.Visit(final) {
+		return errAlreadyMirrored
+	}
+	d.parsedURL = resp.Request.URL
+	d.outputPath = hostDir(d.parsedURL)
+	return nil
+}
+
+// mkdirMirror creates the directory of a mirrored file, which may not
+// follow the original URL after a redirect.
+func (d *download) mkdirMirror() error {
+	return os.MkdirAll(filepath.Dir(d.path), 0755)
+}
@@ -0,0 +1,109 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	stdnet "net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// retryableError marks a failure that is worth another attempt, such as a
+// reset connection, a timeout or a 5xx response.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// statusError turns an unexpected response status into an error. 408, 429 and
+// 5xx responses are retryable; anything else (404, 403, ...) is permanent.
+func statusError(resp *http.Response) error {
+	err := errors.New(resp.Status)
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		return &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+		return &retryableError{err: err}
+	}
+	return err
+}
+
+// transportError wraps err as retryable when it comes from a transient
+// network condition.
+func transportError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if isTransient(err) {
+		return &retryableError{err: err}
+	}
+	return err
+}
+
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr stdnet.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(h); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(h); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// retryDelay returns how long to wait before the next attempt: the server's
+// Retry-After when it sent one, otherwise an exponential backoff starting at
+// one second, capped at maxWait and jittered over its upper half.
+func retryDelay(attempt int, maxWait time.Duration, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	if maxWait <= 0 {
+		return 0
+	}
+
+	d := maxWait
+	if attempt < 32 {
+		if backoff := time.Second << (attempt - 1); backoff < maxWait {
+			d = backoff
+		}
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,232 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"wget/flag"
+	"wget/state"
+)
+
+// robotsMaxSize is how much of a robots.txt is read, as RFC 9309 requires
+// crawlers to parse at least 500 KiB.
+const robotsMaxSize = 500 * 1024
+
+// robots holds the rules of the robots.txt group that applies to us, and the
+// sitemaps the file lists.
+type robots struct {
+	rules    []robotsRule
+	delay    time.Duration
+	sitemaps []string
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+// hostRobotsEntry is the robots.txt of an origin, fetched only once.
+type hostRobotsEntry struct {
+	once   sync.Once
+	robots *robots
+}
+
+var (
+	robotsMu     sync.Mutex
+	robotsByHost = map[string]*hostRobotsEntry{}
+)
+
+// LoadRobots fetches the robots.txt of the host of u, unless -e robots=off
+// was given. Its Crawl-delay slows the whole crawl down.
+func LoadRobots(u *url.URL) {
+	if flag.Robots() {
+		hostRobots(u)
+	}
+}
+
+// RobotsAllowed reports whether robots.txt lets us crawl u.
+func RobotsAllowed(u string) bool {
+	if !flag.Robots() {
+		return true
+	}
+	parsedURL, err := url.Parse(u)
+	if err != nil || parsedURL.Host == "" {
+		return true
+	}
+	path := parsedURL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if parsedURL.RawQuery != "" {
+		path += "?" + parsedURL.RawQuery
+	}
+	return hostRobots(parsedURL).allows(path)
+}
+
+// hostRobots returns the rules of the host of u, fetching its robots.txt the
+// first time. Only checks on the same origin wait for the fetch.
+func hostRobots(u *url.URL) *robots {
+	origin := u.Scheme + "://" + u.Host
+
+	robotsMu.Lock()
+	entry, ok := robotsByHost[origin]
+	if !ok {
+		entry = &hostRobotsEntry{}
+		robotsByHost[origin] = entry
+	}
+	robotsMu.Unlock()
+
+	entry.once.Do(func() {
+		entry.robots = fetchRobots(origin + "/robots.txt")
+		if entry.robots.delay > 0 && flag.Robots() {
+			state.SetCrawlDelay(entry.robots.delay)
+		}
+	})
+	return entry.robots
+}
+
+// fetchRobots reads a robots.txt. A missing or unreadable one allows
+// everything.
+func fetchRobots(u string) *robots {
+	req, err := NewRequest(context.Background(), "GET", u)
+	if err != nil {
+		return &robots{}
+	}
+	resp, err := Client().Do(req)
+	if err != nil {
+		return &robots{}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &robots{}
+	}
+
+	var r io.Reader = resp.Body
+	if decodes(resp) {
+		decoder, err := NewDecoder(resp.Body, resp.Header.Get("Content-Encoding"))
+		if err != nil {
+			return &robots{}
+		}
+		defer decoder.Close()
+		r = decoder
+	}
+	return parseRobots(io.LimitReader(r, robotsMaxSize), req.Header.Get("User-Agent"))
+}
+
+// parseRobots keeps the rules of the groups naming our User-Agent, or of the
+// "*" groups when none does.
+func parseRobots(r io.Reader, userAgent string) *robots {
+	agents := agentTokens(userAgent)
+	var ours, anyone robots
+	foundOurs := false
+	var sitemaps []string
+
+	var group []*robots
+	inAgents := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive User-agent lines share the rules that follow.
+			if !inAgents {
+				group = nil
+				inAgents = true
+			}
+			if value == "*" {
+				group = append(group, &anyone)
+			} else if slices.Contains(agents, strings.ToLower(value)) {
+				group = append(group, &ours)
+				foundOurs = true
+			}
+		case "allow", "disallow":
+			inAgents = false
+			// An empty Disallow allows everything.
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", length: len(value), pattern: robotsPattern(value)}
+			for _, g := range group {
+				g.rules = append(g.rules, rule)
+			}
+		case "sitemap":
+			// Sitemaps apply to every User-Agent.
+			if value != "" {
+				sitemaps = append(sitemaps, value)
+			}
+		case "crawl-delay":
+			inAgents = false
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds <= 0 {
+				continue
+			}
+			for _, g := range group {
+				g.delay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	ours.sitemaps, anyone.sitemaps = sitemaps, sitemaps
+	if foundOurs {
+		return &ours
+	}
+	return &anyone
+}
+
+// agentTokens returns the product names of a User-Agent, such as mozilla,
+// gecko and firefox, along with wget itself.
+func agentTokens(userAgent string) []string {
+	tokens := []string{"wget"}
+	for _, field := range strings.Fields(userAgent) {
+		name, _, ok := strings.Cut(field, "/")
+		if ok && name != "" && !strings.HasPrefix(name, "(") {
+			tokens = append(tokens, strings.ToLower(name))
+		}
+	}
+	return tokens
+}
+
+// robotsPattern compiles a rule path, where * matches any characters and a
+// trailing $ anchors the end of the URL.
+func robotsPattern(p string) *regexp.Regexp {
+	anchored := strings.HasSuffix(p, "$")
+	p = strings.TrimSuffix(p, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allows applies the longest matching rule to path. Allow wins a tie.
+func (r *robots) allows(path string) bool {
+	allow, longest := true, -1
+	for _, rule := range r.rules {
+		if rule.length < longest || !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > longest {
+			allow = rule.allow
+		} else {
+			allow = allow || rule.allow
+		}
+		longest = rule.length
+	}
+	return allow
+}
@@ -0,0 +1,39 @@
+package net
+
+import (
+	"net/url"
+	"strings"
+	"wget/flag"
+	"wget/state"
+)
+
+// InScope reports whether the mirror crawls u: its host is allowed and, on
+// the host of the first page, its path is within the --scope. Page
+// requisites are crawled wherever they are.
+func InScope(u *url.URL) bool {
+	if !HostAllowed(u) {
+		return false
+	}
+	base := state.GetBaseUrl()
+	if state.IsRequisite(u.String()) || !strings.EqualFold(u.Hostname(), base.Hostname()) {
+		return true
+	}
+
+	switch flag.GetScope() {
+	case "directory":
+		return strings.HasPrefix(u.Path, startDir(base))
+	case "prefix":
+		return strings.HasPrefix(u.Path, base.Path)
+	}
+	return true
+}
+
+// startDir is the directory of the first page: its path up to the last
+// slash, like /docs/v2/ for /docs/v2/index.html.
+func startDir(base *url.URL) string {
+	i := strings.LastIndex(base.Path, "/")
+	if i < 0 {
+		return "/"
+	}
+	return base.Path[:i+1]
+}
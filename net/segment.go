@@ -0,0 +1,238 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"wget/flag"
+	"wget/state"
+	"wget/utils"
+
+	"github.com/vbauerster/mpb"
+	"golang.org/x/time/rate"
+)
+
+const segmentBufferSize = 32 * 1024
+
+// sharedLimitReader throttles reads against a limiter that is shared by every
+// segment of a download, so that --rate-limit caps the total speed.
+type sharedLimitReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *sharedLimitReader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func newSharedLimiter(limit int64) *rate.Limiter {
+	if limit <= 0 {
+		return rate.NewLimiter(rate.Inf, segmentBufferSize)
+	}
+	burst := segmentBufferSize
+	if limit < int64(burst) {
+		burst = int(limit)
+	}
+	return rate.NewLimiter(rate.Limit(limit), burst)
+}
+
+type segment struct {
+	start int64
+	end   int64
+}
+
+func splitSegments(length int64, n int) []segment {
+	if int64(n) > length {
+		n = int(length)
+	}
+	size := length / int64(n)
+
+	segments := make([]segment, 0, n)
+	for i := 0; i < n; i++ {
+		start := int64(i) * size
+		end := start + size - 1
+		if i == n-1 {
+			end = length - 1
+		}
+		segments = append(segments, segment{start: start, end: end})
+	}
+	return segments
+}
+
+// canSegment reports whether the download can be split into byte ranges:
+// --segments asks for it, the server advertised range support and a length,
+// and there is no partial file on disk to resume instead.
+func (d *download) canSegment(resume bool) bool {
+	segments := *flag.GetFlagValue(flag.SEGMENTS_FLAG).(*int)
+	if segments <= 1 || !d.infos.AcceptRanges || d.infos.ContentLenght <= 0 {
+		return false
+	}
+	if fileRejected(d.url, d.infos) {
+		// A rejected page is only read for its links.
+		return false
+	}
+	if flag.Provided(flag.TIMESTAMPING_FLAG) && d.existingFile() != "" {
+		// Only a single GET can be made conditional on the local copy.
+		return false
+	}
+	if !resume {
+		return true
+	}
+	for _, path := range []string{d.path, d.partPath()} {
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// getSegmented downloads the file as n byte ranges fetched concurrently. The
+// file is preallocated to its full length and every segment writes its range
+// in place. A failed attempt removes the .part file, since its holes can't be
+// told apart from downloaded bytes.
+func (d *download) getSegmented(p *mpb.Progress, n int) (err error) {
+	contentLength := d.infos.ContentLenght
+
+	if err := d.claimPath(false); err != nil {
+		return err
+	}
+	d.printFinalURL()
+
+	out_file, err := os.Create(d.partPath())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		out_file.Close()
+		if err != nil {
+			os.Remove(d.partPath())
+		}
+	}()
+
+	if err := out_file.Truncate(contentLength); err != nil {
+		return err
+	}
+	d.digests = d.newDigests(nil)
+
+	if state.IsBackground() {
+		fmt.Printf("Getting %s\n", d.url)
+		fmt.Printf("content size: %s, %d segments\n", utils.ConvertedLenghtStr(contentLength), n)
+	}
+
+	var bar *mpb.Bar
+	if !state.IsBackground() {
+		bar = newBar(p, contentLength, d.filename, d.outputPath, fmt.Sprintf("%d segments", n))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	limiter := newSharedLimiter(d.speedLimit)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for _, s := range splitSegments(contentLength, n) {
+		wg.Add(1)
+		go func(s segment) {
+			defer wg.Done()
+			err := getSegment(ctx, d.url, out_file, s, limiter, bar)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		if bar != nil {
+			p.Abort(bar, true)
+		}
+		return firstErr
+	}
+	if err := d.commit(out_file); err != nil {
+		if bar != nil {
+			p.Abort(bar, true)
+		}
+		return err
+	}
+	return nil
+}
+
+func getSegment(ctx context.Context, u string, out_file *os.File, s segment, limiter *rate.Limiter, bar *mpb.Bar) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := NewRequest(ctx, "GET", u)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", s.start, s.end))
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := Client().Do(req)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return statusError(resp)
+	}
+	if start, _, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || start != s.start {
+		return fmt.Errorf("range %d-%d: unexpected Content-Range %q", s.start, s.end, resp.Header.Get("Content-Range"))
+	}
+
+	_, readTimeout := getTimeouts()
+	body := newIdleTimeoutReader(resp.Body, readTimeout, cancel)
+	defer body.Stop()
+
+	reader := &sharedLimitReader{ctx: ctx, reader: body, limiter: limiter}
+	buf := make([]byte, segmentBufferSize)
+	offset := s.start
+
+	for offset <= s.end {
+		n, err := reader.Read(buf)
+		if int64(n) > s.end-offset+1 {
+			n = int(s.end - offset + 1)
+		}
+		if n > 0 {
+			if _, werr := out_file.WriteAt(buf[:n], offset); werr != nil {
+				return werr
+			}
+			offset += int64(n)
+			if bar != nil {
+				bar.IncrBy(n)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return transportError(err)
+		}
+	}
+
+	if offset <= s.end {
+		return &retryableError{err: fmt.Errorf("range %d-%d: connection closed after %d bytes", s.start, s.end, offset-s.start)}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package net
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"wget/state"
+)
+
+// sitemapMaxSize is the largest uncompressed sitemap the protocol allows.
+const sitemapMaxSize = 50 * 1024 * 1024
+
+// maxSitemapDepth bounds how deep sitemap indexes may nest.
+const maxSitemapDepth = 5
+
+// sitemap is either a <urlset> listing pages or a <sitemapindex> listing
+// other sitemaps.
+type sitemap struct {
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		Lastmod string `xml:"lastmod"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// lastmodFormats are the W3C datetime forms a <lastmod> can take.
+var lastmodFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+}
+
+// Sitemaps returns the pages listed in the sitemaps of the site at base, and
+// remembers their <lastmod>. spec is the --sitemap URL, or "auto" to use the
+// sitemaps named in robots.txt, falling back to /sitemap.xml.
+func Sitemaps(base *url.URL, spec string) []string {
+	var pages []string
+	seen := map[string]bool{}
+
+	var read func(u string, depth int)
+	read = func(u string, depth int) {
+		if seen[u] || depth > maxSitemapDepth {
+			return
+		}
+		seen[u] = true
+
+		doc, err := fetchSitemap(u)
+		if err != nil {
+			fmt.Printf("couldn't get sitemap %s. reason: %v\n", u, err)
+			return
+		}
+		for _, s := range doc.Sitemaps {
+			read(strings.TrimSpace(s.Loc), depth+1)
+		}
+		for _, page := range doc.URLs {
+			loc := strings.TrimSpace(page.Loc)
+			if loc == "" {
+				continue
+			}
+			pages = append(pages, loc)
+			if lastmod, ok := parseLastmod(page.Lastmod); ok {
+				state.SetLastmod(loc, lastmod)
+			}
+		}
+	}
+
+	for _, u := range sitemapLocations(base, spec) {
+		read(u, 0)
+	}
+	fmt.Printf("Found %d URLs in sitemaps.\n", len(pages))
+	return pages
+}
+
+func sitemapLocations(base *url.URL, spec string) []string {
+	if spec != "auto" {
+		u, err := base.Parse(spec)
+		if err != nil {
+			return nil
+		}
+		return []string{u.String()}
+	}
+	if sitemaps := hostRobots(base).sitemaps; len(sitemaps) > 0 {
+		return sitemaps
+	}
+	return []string{base.Scheme + "://" + base.Host + "/sitemap.xml"}
+}
+
+// fetchSitemap reads a sitemap or sitemap index, which may be gzipped.
+func fetchSitemap(u string) (*sitemap, error) {
+	req, err := NewRequest(context.Background(), "GET", u)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	var r io.Reader = resp.Body
+	if decodes(resp) {
+		decoder, err := NewDecoder(resp.Body, resp.Header.Get("Content-Encoding"))
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		r = decoder
+	}
+
+	// sitemap.xml.gz files are usually served as is, without a
+	// Content-Encoding.
+	br := bufio.NewReader(r)
+	r = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	doc := &sitemap{}
+	if err := xml.NewDecoder(io.LimitReader(r, sitemapMaxSize)).Decode(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func parseLastmod(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range lastmodFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// unchangedPage returns the local copy of a page whose sitemap <lastmod> is
+// not after it, which then isn't downloaded again.
+func (d *download) unchangedPage() string {
+	lastmod, ok := state.GetLastmod(d.url)
+	if !ok {
+		return ""
+	}
+	local := d.existingFile()
+	if local == "" {
+		return ""
+	}
+	info, err := os.Stat(local)
+	if err != nil || lastmod.After(info.ModTime()) {
+		return ""
+	}
+	return local
+}
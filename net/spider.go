@@ -0,0 +1,177 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"wget/flag"
+	"wget/state"
+)
+
+var (
+	brokenMu sync.Mutex
+	broken   = map[string]string{}
+)
+
+// spiderPage is what --spider learned about a URL. The content of HTML and
+// CSS resources is kept in memory for the link extractor.
+type spiderPage struct {
+	infos    FileInfos
+	finalURL string
+	content  []byte
+}
+
+// Spider checks that u exists without saving anything. When mirroring, HTML
+// and CSS are read in memory and handed to the link extractor; other
+// resources are only probed.
+func Spider(u string) {
+	tries := *flag.GetFlagValue(flag.TRIES_FLAG).(*int)
+	waitRetry := time.Duration(*flag.GetFlagValue(flag.WAITRETRY_FLAG).(*int)) * time.Second
+
+	for attempt := 1; ; attempt++ {
+		page, err := spiderGet(u)
+		if err == nil {
+			if flag.IsMirror() && page.content != nil && (!fileRejected(u, page.infos) || crawlsRejected(page.infos)) {
+				state.AddBytes(int64(len(page.content)))
+				if page.finalURL != u {
+					state.AddRedirect(u, page.finalURL)
+				}
+				pageURL, _ := url.Parse(page.finalURL)
+				state.AddToReadyExtract(state.FileToProcess{Url: pageURL, Content: page.content})
+			} else if flag.IsMirror() {
+				state.Abort(u)
+			} else {
+				fmt.Printf("Remote file exists: %s\n", u)
+			}
+			return
+		}
+
+		var retryErr *retryableError
+		if errors.As(err, &retryErr) && (tries == 0 || attempt < tries) {
+			delay := retryDelay(attempt, waitRetry, retryErr.retryAfter)
+			fmt.Printf("couldn't get %s. reason: %v. retrying in %v (attempt %d)\n\n", u, err, delay.Round(time.Millisecond), attempt+1)
+			time.Sleep(delay)
+			continue
+		}
+
+		reason := err.Error()
+		if parsedURL, parseErr := url.Parse(u); parseErr == nil {
+			if certErr := certificateError(parsedURL.Host, err); certErr != "" {
+				reason = certErr
+			}
+		}
+		fmt.Printf("broken link %s. reason: %s\n", u, reason)
+		brokenMu.Lock()
+		broken[u] = reason
+		brokenMu.Unlock()
+		state.Fail()
+		if flag.IsMirror() {
+			state.Abort(u)
+		}
+		return
+	}
+}
+
+// spiderGet probes u with a HEAD request, or a one byte ranged GET for
+// servers that don't allow HEAD. When mirroring, the pages links are
+// extracted from are then fetched in full.
+func spiderGet(u string) (*spiderPage, error) {
+	resp, err := spiderRequest(u, "HEAD")
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = spiderRequest(u, "GET")
+	}
+	if err != nil {
+		return nil, transportError(err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, statusError(resp)
+	}
+
+	page := &spiderPage{infos: fileInfosFromResponse(resp), finalURL: resp.Request.URL.String()}
+	if !flag.IsMirror() || !hasLinks(page.infos.ContentType) {
+		return page, nil
+	}
+	// A page that redirects to one already crawled adds no links.
+	if page.finalURL != u && !state.Visit(page.finalURL) {
+		return page, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := NewRequest(ctx, "GET", u)
+	if err != nil {
+		return nil, err
+	}
+	resp, err = Client().Do(req)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, statusError(resp)
+	}
+
+	_, readTimeout := getTimeouts()
+	body := newIdleTimeoutReader(resp.Body, readTimeout, cancel)
+	defer body.Stop()
+
+	page.content, err = readContent(resp, body)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	page.finalURL = resp.Request.URL.String()
+	return page, nil
+}
+
+func spiderRequest(u string, method string) (*http.Response, error) {
+	req, err := NewRequest(context.Background(), method, u)
+	if err != nil {
+		return nil, err
+	}
+	if method == "GET" {
+		req.Header.Set("Range", "bytes=0-0")
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+	resp, err := Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func hasLinks(contentType string) bool {
+	return strings.Contains(contentType, "text/html") || strings.Contains(contentType, "text/css")
+}
+
+// SpiderReport prints the broken links found by --spider, with the pages
+// that referenced them.
+func SpiderReport() {
+	brokenMu.Lock()
+	defer brokenMu.Unlock()
+
+	if len(broken) == 0 {
+		fmt.Println("Found no broken links.")
+		return
+	}
+
+	fmt.Printf("Found %d broken links.\n\n", len(broken))
+	urls := make([]string, 0, len(broken))
+	for u := range broken {
+		urls = append(urls, u)
+	}
+	slices.Sort(urls)
+	for _, u := range urls {
+		fmt.Printf("%s (%s)\n", u, broken[u])
+		for _, referrer := range state.GetReferrers(u) {
+			fmt.Printf("    referenced by %s\n", referrer)
+		}
+	}
+	fmt.Println()
+}
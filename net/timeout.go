@@ -0,0 +1,107 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+	"wget/flag"
+)
+
+// defaultReadTimeout matches wget: a transfer that receives nothing for 15
+// minutes is given up on.
+const defaultReadTimeout = 900 * time.Second
+
+// defaultDialTimeout is the dial timeout of http.DefaultTransport, kept along
+// with its 10s TLS handshake timeout when no connect timeout is set.
+const defaultDialTimeout = 30 * time.Second
+
+// getTimeouts resolves --timeout, --connect-timeout and --read-timeout. The
+// connect timeout covers DNS, TCP connect and the TLS handshake; the read
+// timeout covers the wait for response headers and any idle period while
+// reading the body. The connect timeout only applies when set; the read
+// timeout defaults to defaultReadTimeout.
+func getTimeouts() (connect time.Duration, read time.Duration) {
+	all := seconds(*flag.GetFlagValue(flag.TIMEOUT_FLAG).(*float64))
+	connect = all
+	read = all
+	if read == 0 {
+		read = defaultReadTimeout
+	}
+
+	if flag.Provided(flag.CONNECT_TIMEOUT_FLAG) {
+		connect = seconds(*flag.GetFlagValue(flag.CONNECT_TIMEOUT_FLAG).(*float64))
+	}
+	if flag.Provided(flag.READ_TIMEOUT_FLAG) {
+		read = seconds(*flag.GetFlagValue(flag.READ_TIMEOUT_FLAG).(*float64))
+	}
+	return connect, read
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// idleTimeoutError is returned when a response body stays silent for longer
+// than the read timeout. It reports itself as a timeout so the download is
+// retried.
+type idleTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *idleTimeoutError) Error() string {
+	return fmt.Sprintf("read timed out: no data received for %v", e.timeout)
+}
+
+func (e *idleTimeoutError) Timeout() bool {
+	return true
+}
+
+func (e *idleTimeoutError) Temporary() bool {
+	return true
+}
+
+// idleTimeoutReader cancels the request when no bytes arrive for timeout,
+// which unblocks a Read that would otherwise hang forever on a stalled
+// server.
+type idleTimeoutReader struct {
+	reader  io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+func newIdleTimeoutReader(r io.Reader, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutReader {
+	t := &idleTimeoutReader{
+		reader:  r,
+		timeout: timeout,
+	}
+	if timeout > 0 {
+		t.timer = time.AfterFunc(timeout, func() {
+			t.expired.Store(true)
+			cancel()
+		})
+	}
+	return t
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if r.timer == nil {
+		return n, err
+	}
+	if err != nil && r.expired.Load() {
+		return n, &idleTimeoutError{timeout: r.timeout}
+	}
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+func (r *idleTimeoutReader) Stop() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
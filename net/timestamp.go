@@ -0,0 +1,144 @@
+package net
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"wget/flag"
+)
+
+// etagFileName is kept in the -P directory by --timestamping to remember the
+// ETag of every file it downloaded, for If-None-Match on the next run.
+const etagFileName = ".wget-etags"
+
+var (
+	etagsMu      sync.Mutex
+	etags        map[string]string
+	etagsChanged bool
+)
+
+// setConditionalHeaders makes the request conditional on the local copy of
+// the file, if there is one, and returns its path.
+func setConditionalHeaders(req *http.Request, d *download) string {
+	local := d.existingFile()
+	if local == "" {
+		return ""
+	}
+	info, err := os.Stat(local)
+	if err != nil {
+		return ""
+	}
+
+	req.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
+	if etag := storedETag(local); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	return local
+}
+
+// notNewer covers servers that ignore conditional requests: a 200 whose
+// Last-Modified is not after the local copy, with the same size, is the file
+// we already have. An encoded body has no size to compare with a copy saved
+// decoded.
+func notNewer(resp *http.Response, local string) bool {
+	info, err := os.Stat(local)
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	if resp.Header.Get("Content-Encoding") != "" {
+		return !lastModified.After(info.ModTime())
+	}
+	return !lastModified.After(info.ModTime()) && resp.ContentLength == info.Size()
+}
+
+// applyServerTimestamp gives a finished download the server's Last-Modified
+// date, and with --timestamping remembers its ETag.
+func (d *download) applyServerTimestamp() {
+	if lastModified, err := http.ParseTime(d.infos.LastModified); err == nil {
+		os.Chtimes(d.path, time.Now(), lastModified)
+	}
+	if flag.Provided(flag.TIMESTAMPING_FLAG) && d.infos.ETag != "" {
+		storeETag(d.path, d.infos.ETag)
+	}
+}
+
+func storedETag(path string) string {
+	etagsMu.Lock()
+	defer etagsMu.Unlock()
+
+	rel, err := filepath.Rel(etagDir(), path)
+	if err != nil {
+		return ""
+	}
+	return loadETags()[rel]
+}
+
+func storeETag(path string, etag string) {
+	etagsMu.Lock()
+	defer etagsMu.Unlock()
+
+	rel, err := filepath.Rel(etagDir(), path)
+	if err != nil {
+		return
+	}
+	loadETags()[rel] = etag
+	etagsChanged = true
+}
+
+// SaveETags writes the ETags of the run to the ETag file, once every
+// download is done.
+func SaveETags() error {
+	etagsMu.Lock()
+	defer etagsMu.Unlock()
+
+	if !etagsChanged {
+		return nil
+	}
+
+	var b strings.Builder
+	for rel, etag := range etags {
+		b.WriteString(etag + "\t" + rel + "\n")
+	}
+
+	dir := etagDir()
+	tmp := filepath.Join(dir, etagFileName+".part")
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, etagFileName))
+}
+
+func etagDir() string {
+	return *flag.GetFlagValue(flag.PATH_FLAG).(*string)
+}
+
+// loadETags reads the ETag file once. etagsMu must be held.
+func loadETags() map[string]string {
+	if etags != nil {
+		return etags
+	}
+	etags = map[string]string{}
+
+	file, err := os.Open(filepath.Join(etagDir(), etagFileName))
+	if err != nil {
+		return etags
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		etag, rel, ok := strings.Cut(scanner.Text(), "\t")
+		if ok {
+			etags[rel] = etag
+		}
+	}
+	return etags
+}
@@ -0,0 +1,161 @@
+package net
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"wget/flag"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// errPinMismatch is returned when the server's public key matches none of
+// the --pinnedpubkey hashes.
+var errPinMismatch = errors.New("public key does not match --pinnedpubkey")
+
+func newTLSConfig() (*tls.Config, error) {
+	minVersion, ok := tlsVersions[*flag.GetFlagValue(flag.MIN_TLS_FLAG).(*string)]
+	if !ok {
+		return nil, fmt.Errorf("invalid min-tls-version %q. usage: --min-tls-version 1.2", *flag.GetFlagValue(flag.MIN_TLS_FLAG).(*string))
+	}
+
+	cfg := &tls.Config{MinVersion: minVersion}
+
+	if flag.Provided(flag.CA_CERTIFICATE_FLAG) || flag.Provided(flag.CA_DIRECTORY_FLAG) {
+		pool, err := loadCertPool(*flag.GetFlagValue(flag.CA_CERTIFICATE_FLAG).(*string), *flag.GetFlagValue(flag.CA_DIRECTORY_FLAG).(*string))
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if flag.Provided(flag.CERTIFICATE_FLAG) {
+		certFile := *flag.GetFlagValue(flag.CERTIFICATE_FLAG).(*string)
+		keyFile := certFile
+		if flag.Provided(flag.PRIVATE_KEY_FLAG) {
+			keyFile = *flag.GetFlagValue(flag.PRIVATE_KEY_FLAG).(*string)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	} else if flag.Provided(flag.PRIVATE_KEY_FLAG) {
+		return nil, fmt.Errorf("private-key requires --certificate")
+	}
+
+	if flag.Provided(flag.PINNED_PUBKEY_FLAG) {
+		pins, err := parsePins(*flag.GetFlagValue(flag.PINNED_PUBKEY_FLAG).(*string))
+		if err != nil {
+			return nil, err
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errPinMismatch
+			}
+			sum := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+			if !pins[base64.StdEncoding.EncodeToString(sum[:])] {
+				return errPinMismatch
+			}
+			return nil
+		}
+	}
+
+	if flag.Provided(flag.NO_CHECK_CERTIFICATE_FLAG) {
+		cfg.InsecureSkipVerify = true
+		os.Stderr.WriteString("WARNING: certificate verification is disabled (--no-check-certificate). Connections can be intercepted.\n")
+	}
+
+	return cfg, nil
+}
+
+// loadCertPool adds the PEM certificates of caFile and of every file in
+// caDir to the system roots.
+func loadCertPool(caFile string, caDir string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load CA certificate: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("cannot load CA certificate: no PEM certificates found in %s", caFile)
+		}
+	}
+
+	if caDir != "" {
+		entries, err := os.ReadDir(caDir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load CA directory: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			// Hashed symlinks and non-certificate files are skipped silently,
+			// as OpenSSL does.
+			if pem, err := os.ReadFile(filepath.Join(caDir, entry.Name())); err == nil {
+				pool.AppendCertsFromPEM(pem)
+			}
+		}
+	}
+
+	return pool, nil
+}
+
+// parsePins reads --pinnedpubkey in curl's "sha256//<base64>;sha256//<base64>"
+// form into a set of base64 SHA-256 hashes of the SubjectPublicKeyInfo.
+func parsePins(s string) (map[string]bool, error) {
+	pins := map[string]bool{}
+	for _, pin := range strings.Split(s, ";") {
+		hash, ok := strings.CutPrefix(strings.TrimSpace(pin), "sha256//")
+		if decoded, err := base64.StdEncoding.DecodeString(hash); !ok || err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid pinnedpubkey %q. usage: --pinnedpubkey sha256//<base64 hash>", pin)
+		}
+		pins[hash] = true
+	}
+	return pins, nil
+}
+
+// certificateError explains a failed TLS verification in terms of what the
+// user can do about it. It returns "" for any other error.
+func certificateError(host string, err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.Is(err, errPinMismatch):
+		return fmt.Sprintf("the public key of %s does not match --pinnedpubkey", host)
+	case errors.As(err, &unknownAuthority):
+		return fmt.Sprintf("the certificate of %s is not signed by a trusted authority. Use --ca-certificate to trust a private CA, or --no-check-certificate to skip verification (insecure)", host)
+	case errors.As(err, &hostnameErr):
+		return fmt.Sprintf("the certificate of %s is not valid for that name: %v", host, hostnameErr)
+	case errors.As(err, &invalidErr):
+		if invalidErr.Reason == x509.Expired {
+			return fmt.Sprintf("the certificate of %s has expired or is not yet valid", host)
+		}
+		return fmt.Sprintf("the certificate of %s is invalid: %v", host, invalidErr)
+	}
+
+	var verifyErr *tls.CertificateVerificationError
+	if errors.As(err, &verifyErr) {
+		return fmt.Sprintf("the certificate of %s could not be verified: %v", host, verifyErr.Err)
+	}
+	return ""
+}
@@ -1,9 +1,13 @@
 package state
 
 import (
+	"context"
+	"fmt"
 	"net/url"
 	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -17,11 +21,25 @@ type MirrorState struct {
 	limiter        *rate.Limiter
 	ReadyToExtract chan FileToProcess
 	URLMap         *sync.Map
+	Redirects      *sync.Map
+	// Depths is the number of links followed from the first page to
+	// reach each URL.
+	Depths *sync.Map
+	// Lastmods is the <lastmod> of the pages listed in sitemaps.
+	Lastmods *sync.Map
+	// Requisites are the resources pages embed, fetched with
+	// --page-requisites from any host.
+	Requisites *sync.Map
 }
 
 type FileToProcess struct {
 	Path string
 	Url  *url.URL
+	// Encoding is the Content-Encoding of a file saved without decoding it.
+	Encoding string
+	// Content holds a page read in memory instead of saving it: every page
+	// with --spider, and rejected HTML pages whose links are followed.
+	Content []byte
 }
 
 type States struct {
@@ -31,12 +49,25 @@ type States struct {
 
 var states States
 
+// failed makes the program exit with a non-zero status once it is done.
+var failed atomic.Bool
+
+// referrers lists the pages each link was found on.
+var (
+	referrersMu sync.Mutex
+	referrers   = map[string][]string{}
+)
+
 func GetStates() *States {
 	return &states
 }
 
+// crawlInterval is the pace of mirror requests, unless robots.txt asks for a
+// longer Crawl-delay.
+const crawlInterval = 250 * time.Millisecond
+
 func InitNewState() {
-	limiter := rate.NewLimiter(rate.Every(time.Millisecond*250), 1) // Allow 1 request per second
+	limiter := rate.NewLimiter(rate.Every(crawlInterval), 1)
 
 	states = States{
 		Mirror: MirrorState{
@@ -46,13 +77,88 @@ func InitNewState() {
 			limiter:        limiter,
 			ReadyToExtract: make(chan FileToProcess),
 			URLMap:         &sync.Map{},
+			Redirects:      &sync.Map{},
+			Depths:         &sync.Map{},
+			Lastmods:       &sync.Map{},
+			Requisites:     &sync.Map{},
 		},
 		Aborted: make(chan string),
 	}
 }
 
+// MapUrlPath records where the file of f.Url was saved, which is what
+// --convert-links points links to it at.
 func MapUrlPath(f FileToProcess) {
-	states.Mirror.URLMap.Store(f.Url, f.Path)
+	u := *f.Url
+	u.Fragment = ""
+	states.Mirror.URLMap.Store(u.String(), f.Path)
+}
+
+// GetUrlPath returns where the file of u was saved, if it was.
+func GetUrlPath(u *url.URL) (string, bool) {
+	withoutFragment := *u
+	withoutFragment.Fragment = ""
+	path, ok := states.Mirror.URLMap.Load(withoutFragment.String())
+	if !ok {
+		return "", false
+	}
+	return path.(string), true
+}
+
+// AddRedirect records that the page at from was saved as the one at to,
+// which is then as deep as from.
+func AddRedirect(from string, to string) {
+	states.Mirror.Redirects.Store(from, to)
+	if depth, ok := GetDepth(from); ok {
+		SetDepth(to, depth)
+	}
+}
+
+// SetDepth records the depth of link, keeping the shortest path to it.
+func SetDepth(link string, depth int) {
+	for {
+		old, loaded := states.Mirror.Depths.LoadOrStore(link, depth)
+		if !loaded || old.(int) <= depth || states.Mirror.Depths.CompareAndSwap(link, old, depth) {
+			return
+		}
+	}
+}
+
+func GetDepth(link string) (int, bool) {
+	depth, ok := states.Mirror.Depths.Load(link)
+	if !ok {
+		return 0, false
+	}
+	return depth.(int), true
+}
+
+func AddRequisite(link string) {
+	states.Mirror.Requisites.Store(link, true)
+}
+
+func IsRequisite(link string) bool {
+	_, ok := states.Mirror.Requisites.Load(link)
+	return ok
+}
+
+func SetLastmod(link string, lastmod time.Time) {
+	states.Mirror.Lastmods.Store(link, lastmod)
+}
+
+func GetLastmod(link string) (time.Time, bool) {
+	lastmod, ok := states.Mirror.Lastmods.Load(link)
+	if !ok {
+		return time.Time{}, false
+	}
+	return lastmod.(time.Time), true
+}
+
+func GetRedirect(u string) (string, bool) {
+	to, ok := states.Mirror.Redirects.Load(u)
+	if !ok {
+		return "", false
+	}
+	return to.(string), true
 }
 
 func IsBackground() bool {
@@ -83,8 +189,33 @@ func GetLimiter() *rate.Limiter {
 	return states.Mirror.limiter
 }
 
-func SetVisitedLink(link string) {
-	states.Mirror.VisitedLinks.Store(link, true)
+// SetCrawlDelay slows the crawl down to one request per delay, if that is
+// slower than its current pace.
+func SetCrawlDelay(delay time.Duration) {
+	if limit := rate.Every(delay); limit < states.Mirror.limiter.Limit() {
+		states.Mirror.limiter.SetLimit(limit)
+	}
+}
+
+// Visit marks link as visited and reports whether it wasn't already, for
+// example as the target of a redirect.
+func Visit(link string) bool {
+	_, loaded := states.Mirror.VisitedLinks.LoadOrStore(link, true)
+	return !loaded
+}
+
+func AddReferrer(link string, page string) {
+	referrersMu.Lock()
+	defer referrersMu.Unlock()
+	if !slices.Contains(referrers[link], page) {
+		referrers[link] = append(referrers[link], page)
+	}
+}
+
+func GetReferrers(link string) []string {
+	referrersMu.Lock()
+	defer referrersMu.Unlock()
+	return slices.Clone(referrers[link])
 }
 
 func GetReadyExtract() chan FileToProcess {
@@ -98,3 +229,87 @@ func AddToReadyExtract(f FileToProcess) {
 func Abort(u string) {
 	states.Aborted <- u
 }
+
+func Fail() {
+	failed.Store(true)
+}
+
+func Failed() bool {
+	return failed.Load()
+}
+
+// budget bounds a crawl by the number of files fetched and the bytes saved.
+// Once it runs out, or the crawl is stopped otherwise, no new file is
+// started and stopReason tells why.
+var budget struct {
+	sync.Mutex
+	maxPages   int
+	pages      int
+	quota      int64
+	bytes      int64
+	stopReason string
+}
+
+// stopped is cancelled once the crawl is stopped, which wakes the pages
+// waiting for the rate limiter.
+var stopped, stopCrawl = context.WithCancel(context.Background())
+
+func SetBudget(maxPages int, quota int64) {
+	budget.Lock()
+	defer budget.Unlock()
+	budget.maxPages = maxPages
+	budget.quota = quota
+}
+
+// StartPage reserves a file of the --max-pages budget. It returns false once
+// the crawl is stopped.
+func StartPage() bool {
+	budget.Lock()
+	defer budget.Unlock()
+	if budget.stopReason != "" {
+		return false
+	}
+	if budget.maxPages > 0 && budget.pages >= budget.maxPages {
+		stop(fmt.Sprintf("reached --max-pages %d", budget.maxPages))
+		return false
+	}
+	budget.pages++
+	return true
+}
+
+// AddBytes counts n bytes saved against the --quota budget.
+func AddBytes(n int64) {
+	budget.Lock()
+	defer budget.Unlock()
+	budget.bytes += n
+	if budget.quota > 0 && budget.bytes >= budget.quota {
+		stop(fmt.Sprintf("reached --quota of %d bytes", budget.quota))
+	}
+}
+
+// Stop ends the crawl for the given reason, unless it already ended.
+func Stop(reason string) {
+	budget.Lock()
+	defer budget.Unlock()
+	stop(reason)
+}
+
+// stop records why the crawl stopped, keeping the first reason. budget must
+// be locked.
+func stop(reason string) {
+	if budget.stopReason == "" {
+		budget.stopReason = reason
+		stopCrawl()
+	}
+}
+
+// Stopped is done once the crawl is stopped.
+func Stopped() context.Context {
+	return stopped
+}
+
+func StopReason() string {
+	budget.Lock()
+	defer budget.Unlock()
+	return budget.stopReason
+}
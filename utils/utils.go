@@ -1,7 +1,6 @@
 package utils
 
 import (
-	"bufio"
 	"fmt"
 	"math"
 	"mime"
@@ -122,19 +121,25 @@ func ConvertedRateLimit(valStr string) int64 {
 	return lim
 }
 
+// ExtractURLs finds the CSS url() and @import "file.css" references in
+// content.
+// cssURLPattern matches the url() and @import references of CSS.
+var cssURLPattern = regexp.MustCompile(`url\(['"]?(.*?)['"]?\)|@import\s+['"](.*?)['"]`)
+
 func ExtractURLs(baseUrl *url.URL, content []byte) []string {
-	re := regexp.MustCompile(`url\(['"]?(.*?)['"]?\)`)
 	// content, _ := io.ReadAll(f)
 
-	matches := re.FindAllStringSubmatch(string(content), -1)
+	matches := cssURLPattern.FindAllStringSubmatch(string(content), -1)
 
 	var urls []string
 
 	for _, match := range matches {
-		if len(match) > 1 {
-			// p := ResolveRelativePath(baseUrl, match[1])
-			// println(p)
-			urls = append(urls, match[1])
+		for _, u := range match[1:] {
+			if u != "" {
+				// p := ResolveRelativePath(baseUrl, match[1])
+				// println(p)
+				urls = append(urls, u)
+			}
 		}
 	}
 
@@ -160,6 +165,11 @@ func IsSameDomain(baseUrl *url.URL, link string) bool {
 	return baseUrl.Hostname() == linkUrl.Hostname()
 }
 
+// MatchesDomain reports whether host is domain or one of its subdomains.
+func MatchesDomain(host string, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
 func ResolveLink(baseUrl *url.URL, link string) string {
 	resolvedUrl, err := baseUrl.Parse(link)
 	if err != nil {
@@ -168,59 +178,17 @@ func ResolveLink(baseUrl *url.URL, link string) string {
 	return resolvedUrl.String()
 }
 
-func ReplaceURLsInFile(filename string) error {
-	// Open the file for reading
-	file, err := os.Open(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Read file contents line by line
-	var fileContent strings.Builder
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		fileContent.WriteString(scanner.Text())
-		fileContent.WriteString("\n") // Add newline to preserve formatting
-	}
-
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-
-	// Define a regular expression to match URLs inside "url('')"
-	re := regexp.MustCompile(`url\(['"]?(.*?)['"]?\)`)
-
-	// Function to replace URLs with relative paths
-	replacer := func(match string) string {
-		// Extract the URL from the match
-		parts := re.FindStringSubmatch(match)
-		if len(parts) > 1 {
-			// Replace with relative path by concatenating with basePath
-			relativePath := parts[1]
-			if strings.HasPrefix(relativePath, "/") {
-				relativePath = "." + relativePath
-			}
-			return fmt.Sprintf("url('%s')", relativePath)
+// ReplaceCSSURLs rewrites the url() and @import references of a stylesheet
+// with replace.
+func ReplaceCSSURLs(css string, replace func(string) string) string {
+	return cssURLPattern.ReplaceAllStringFunc(css, func(match string) string {
+		parts := cssURLPattern.FindStringSubmatch(match)
+		if parts[1] != "" {
+			return fmt.Sprintf("url('%s')", replace(parts[1]))
+		}
+		if parts[2] != "" {
+			return fmt.Sprintf(`@import "%s"`, replace(parts[2]))
 		}
 		return match
-	}
-
-	// Replace all matches using the replacer function
-	modifiedContent := re.ReplaceAllStringFunc(fileContent.String(), replacer)
-
-	// Open the same file for writing and truncate it (clear the content)
-	file, err = os.OpenFile(filename, os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Write the modified content back to the file
-	_, err = file.WriteString(modifiedContent)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	})
 }